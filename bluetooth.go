@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// TransportKind names the link a controller is reached over, which decides
+// the report format.
+type TransportKind int
+
+const (
+	TransportUSB TransportKind = iota
+	TransportBluetooth
+)
+
+func (t TransportKind) String() string {
+	switch t {
+	case TransportUSB:
+		return "usb"
+	case TransportBluetooth:
+		return "bluetooth"
+	}
+	return "unknown"
+}
+
+func ParseTransport(s string) (TransportKind, error) {
+	switch s {
+	case "usb":
+		return TransportUSB, nil
+	case "bluetooth", "bt":
+		return TransportBluetooth, nil
+	}
+	return 0, fmt.Errorf("unknown transport %q, expected usb or bluetooth", s)
+}
+
+const (
+	ProductXboxOneSBluetooth    = 0x02fd
+	ProductXboxOneSBluetoothOld = 0x02e0
+	ProductXboxSeriesBluetooth  = 0x0b13
+	ProductXboxElite2Bluetooth  = 0x0b22
+)
+
+var bluetoothProducts = knownProducts(TransportBluetooth)
+
+type hidrawDevice struct {
+	path    string
+	product gousb.ID
+	name    string
+	uniq    string
+}
+
+const (
+	reportBluetoothInput = 0x01
+	reportBluetoothGuide = 0x02
+)
+
+// Bluetooth input report 0x01 (firmware 5.x and Series controllers):
+//
+//	1-8   LX, LY, RX, RY as uint16, centred on 0x8000, Y pointing down
+//	9-12  LT, RT as uint16, 0-1023
+//	13    d-pad hat, 0 centred, 1 up, then clockwise to 8 up-left
+//	14    A 0x01, B 0x02, X 0x08, Y 0x10, LB 0x40, RB 0x80
+//	15    VIEW 0x04, MENU 0x08, GUIDE 0x10, LS 0x20, RS 0x40
+//	16    SHARE 0x01 (Series controllers only)
+//
+// Like updateReport, each report only sets the fields it carries: a 16-byte
+// 0x01 report leaves SHARE alone. Older firmware sends the guide button
+// separately as report 0x02, byte 1, and leaves the 0x01 guide bit clear;
+// with guideReport set, report 0x01 keeps GUIDE as the last 0x02 report left
+// it. Some firmware uses the same HID layout over USB; see ReportFormatHID.
+func updateBluetoothReport(state *ControllerState, buf []byte, deadzone float32, guideReport bool) error {
+	n := len(buf)
+	if n == 0 {
+		return fmt.Errorf("%w: %d bytes", ErrShortRead, n)
+	}
+
+	switch buf[0] {
+	case reportBluetoothGuide:
+		if n < 2 {
+			return fmt.Errorf("%w: %d bytes for bluetooth report 0x%02x, need 2", ErrShortRead, n, buf[0])
+		}
+		state.ReportID = buf[0]
+		state.GUIDE = buf[1]&0x01 != 0
+		return nil
+	case reportBluetoothInput:
+	default:
+		state.ReportID = buf[0]
+		return nil
+	}
+	if n < 16 {
+		return fmt.Errorf("%w: %d bytes for bluetooth report 0x%02x, need 16", ErrShortRead, n, buf[0])
+	}
+
+	state.ReportID = buf[0]
+
+	axis := func(off int) float32 {
+		return float32(int(binary.LittleEndian.Uint16(buf[off:off+2]))-0x8000) / stickRange
+	}
+	state.LEFTX = axis(1)
+	state.LEFTY = -axis(3)
+	state.RIGHTX = axis(5)
+	state.RIGHTY = -axis(7)
+	state.LT = float32(binary.LittleEndian.Uint16(buf[9:11])&0x3ff) / triggerRange
+	state.RT = float32(binary.LittleEndian.Uint16(buf[11:13])&0x3ff) / triggerRange
+
+	hat := buf[13]
+	state.UP = hat == 1 || hat == 2 || hat == 8
+	state.RIGHT = hat >= 2 && hat <= 4
+	state.DOWN = hat >= 4 && hat <= 6
+	state.LEFT = hat >= 6 && hat <= 8
+
+	state.A = buf[14]&0x01 != 0
+	state.B = buf[14]&0x02 != 0
+	state.X = buf[14]&0x08 != 0
+	state.Y = buf[14]&0x10 != 0
+	state.LB = buf[14]&0x40 != 0
+	state.RB = buf[14]&0x80 != 0
+	state.VIEW = buf[15]&0x04 != 0
+	state.MENU = buf[15]&0x08 != 0
+	if !guideReport {
+		state.GUIDE = buf[15]&0x10 != 0
+	}
+	state.LS = buf[15]&0x20 != 0
+	state.RS = buf[15]&0x40 != 0
+	if n > 16 {
+		state.SHARE = buf[16]&0x01 != 0
+	}
+
+	applyDeadzone(state, deadzone)
+
+	return nil
+}
+
+// Bluetooth rumble output report 0x03: motor enable mask, the four
+// magnitudes (0-100), sustain and release in 10ms units, and loop count.
+func bluetoothRumblePacket(r Rumble) []byte {
+	return []byte{
+		0x03, 0x0f,
+		rumbleLevel(r.LeftTrigger), rumbleLevel(r.RightTrigger),
+		rumbleLevel(r.Strong), rumbleLevel(r.Weak),
+		0xff, 0x00, 0xeb,
+	}
+}
+
+// hidrawTransport reads a Bluetooth-paired controller through the kernel's
+// HID driver. Reads block on the file, so cancellation goes through a read
+// deadline.
+type hidrawTransport struct {
+	f        *os.File
+	dev      hidrawDevice
+	readOnly bool
+}
+
+func (t *hidrawTransport) ReadReport(ctx context.Context, buf []byte) (int, error) {
+	t.f.SetReadDeadline(time.Time{})
+	stop := context.AfterFunc(ctx, func() {
+		t.f.SetReadDeadline(time.Now())
+	})
+	defer stop()
+
+	return t.f.Read(buf)
+}
+
+func (t *hidrawTransport) WriteReport(report []byte) error {
+	if t.readOnly {
+		return ErrReadOnly
+	}
+	n, err := t.f.Write(report)
+	return checkWrite(n, len(report), err)
+}
+
+func (t *hidrawTransport) canWrite() bool {
+	return !t.readOnly
+}
+
+func (t *hidrawTransport) Close() error {
+	return t.f.Close()
+}
+
+func (t *hidrawTransport) Info() ControllerInfo {
+	return ControllerInfo{
+		VendorID:   VendorMicrosoft,
+		ProductID:  t.dev.product,
+		Transport:  TransportBluetooth,
+		Connection: ConnectionWireless,
+	}
+}
+
+func (t *hidrawTransport) Strings() (DeviceStrings, error) {
+	return DeviceStrings{Manufacturer: "Microsoft", Product: t.dev.name, Serial: t.dev.uniq}, nil
+}
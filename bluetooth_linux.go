@@ -0,0 +1,102 @@
+//go:build linux
+
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/google/gousb"
+)
+
+const hidBusBluetooth = 0x0005
+
+func findBluetoothControllers(products []gousb.ID) ([]hidrawDevice, error) {
+	entries, err := filepath.Glob("/sys/class/hidraw/hidraw*")
+	if err != nil {
+		return nil, err
+	}
+
+	var devices []hidrawDevice
+	for _, entry := range entries {
+		f, err := os.Open(filepath.Join(entry, "device", "uevent"))
+		if err != nil {
+			continue
+		}
+
+		var dev hidrawDevice
+		var bus, vendor uint64
+		scanner := bufio.NewScanner(f)
+		for scanner.Scan() {
+			key, value, _ := strings.Cut(scanner.Text(), "=")
+			switch key {
+			case "HID_ID":
+				parts := strings.Split(value, ":")
+				if len(parts) != 3 {
+					continue
+				}
+				bus, _ = strconv.ParseUint(parts[0], 16, 32)
+				vendor, _ = strconv.ParseUint(parts[1], 16, 32)
+				pid, _ := strconv.ParseUint(parts[2], 16, 32)
+				dev.product = gousb.ID(pid)
+			case "HID_NAME":
+				dev.name = value
+			case "HID_UNIQ":
+				dev.uniq = value
+			}
+		}
+		f.Close()
+
+		if bus != hidBusBluetooth || vendor != VendorMicrosoft {
+			continue
+		}
+		for _, pid := range products {
+			if dev.product == pid {
+				dev.path = filepath.Join("/dev", filepath.Base(entry))
+				devices = append(devices, dev)
+				break
+			}
+		}
+	}
+
+	return devices, nil
+}
+
+func (c *Controller) openBluetooth() error {
+	devices, err := findBluetoothControllers(bluetoothProducts)
+	if err != nil {
+		return fmt.Errorf("scanning hidraw devices failed: %v", err)
+	}
+
+	var openErr error
+	for _, dev := range devices {
+		f, err := os.OpenFile(dev.path, os.O_RDWR, 0)
+		readOnly := false
+		if err != nil {
+			f, err = os.OpenFile(dev.path, os.O_RDONLY, 0)
+			readOnly = true
+		}
+		if err != nil {
+			openErr = err
+			continue
+		}
+		if readOnly {
+			log.Printf("No write access to %s, opening read-only", dev.path)
+		}
+
+		log.Printf("Found Xbox controller over Bluetooth with PID: %#x at %s", uint16(dev.product), dev.path)
+		c.attach(&hidrawTransport{f: f, dev: dev, readOnly: readOnly})
+		c.serial = dev.uniq
+		return nil
+	}
+
+	if openErr != nil {
+		return fmt.Errorf("opening hidraw device failed: %w", usbError(openErr))
+	}
+	return fmt.Errorf("%w over Bluetooth", ErrNoDevice)
+}
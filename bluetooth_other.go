@@ -0,0 +1,17 @@
+//go:build !linux
+
+package main
+
+import (
+	"fmt"
+
+	"github.com/google/gousb"
+)
+
+func findBluetoothControllers(products []gousb.ID) ([]hidrawDevice, error) {
+	return nil, nil
+}
+
+func (c *Controller) openBluetooth() error {
+	return fmt.Errorf("bluetooth transport is only supported on Linux")
+}
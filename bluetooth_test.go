@@ -0,0 +1,69 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+// bluetoothInput is a centred 0x01 report with no buttons held.
+func bluetoothInput() []byte {
+	buf := make([]byte, 17)
+	buf[0] = reportBluetoothInput
+	for _, off := range []int{1, 3, 5, 7} {
+		buf[off+1] = 0x80
+	}
+	return buf
+}
+
+func TestBluetoothGuideReportMerges(t *testing.T) {
+	var s ControllerState
+	if err := updateBluetoothReport(&s, []byte{reportBluetoothGuide, 0x01}, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if err := updateBluetoothReport(&s, bluetoothInput(), 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if !s.GUIDE {
+		t.Fatal("input report cleared a guide button held in report 0x02")
+	}
+	if err := updateBluetoothReport(&s, []byte{reportBluetoothGuide, 0x00}, 0, true); err != nil {
+		t.Fatal(err)
+	}
+	if s.GUIDE {
+		t.Fatal("guide release in report 0x02 was not applied")
+	}
+}
+
+func TestBluetoothGuideInInputReport(t *testing.T) {
+	var s ControllerState
+	report := bluetoothInput()
+	report[15] = 0x10
+	if err := updateBluetoothReport(&s, report, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if !s.GUIDE {
+		t.Fatal("guide bit of report 0x01 was ignored")
+	}
+	if err := updateBluetoothReport(&s, bluetoothInput(), 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if s.GUIDE {
+		t.Fatal("guide release in report 0x01 was ignored")
+	}
+}
+
+func TestBluetoothInputKeepsUncarriedFields(t *testing.T) {
+	at := time.Unix(10, 0)
+	s := ControllerState{SHARE: true, UP: true, Time: at, Extra: []byte{1}}
+	report := bluetoothInput()[:16]
+	report[13] = 3
+	if err := updateBluetoothReport(&s, report, 0, false); err != nil {
+		t.Fatal(err)
+	}
+	if !s.SHARE || !s.Time.Equal(at) || len(s.Extra) != 1 {
+		t.Fatalf("16-byte report changed fields it does not carry: %+v", s)
+	}
+	if s.UP || !s.RIGHT {
+		t.Fatalf("hat 3 decoded as UP=%v RIGHT=%v, want right only", s.UP, s.RIGHT)
+	}
+}
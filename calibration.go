@@ -0,0 +1,190 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+var stickAxes = []string{"LEFTX", "LEFTY", "RIGHTX", "RIGHTY"}
+
+// AxisCalibration corrects one stick axis as (v - Offset) * Scale, before
+// the deadzone. A zero Scale counts as 1 and a zero Deadzone falls back to
+// Config.Deadzone, so hand-written entries can leave them out.
+type AxisCalibration struct {
+	Offset   float32 `json:"offset"`
+	Scale    float32 `json:"scale,omitempty"`
+	Deadzone float32 `json:"deadzone,omitempty"`
+}
+
+// Calibration is keyed by stick axis name; axes without an entry are left
+// uncorrected.
+type Calibration map[string]AxisCalibration
+
+func WithCalibration(cal Calibration) Option {
+	return func(c *Controller) {
+		c.cfg.Calibration = cal
+	}
+}
+
+// SetCalibration takes effect from the next read. Call it from the goroutine
+// that reads.
+func (c *Controller) SetCalibration(cal Calibration) {
+	c.cfg.Calibration = cal
+}
+
+// apply runs in place of the decode-time deadzone, which would otherwise
+// hide the drift the offsets are there to remove. With radial set the
+// caller applies the radial deadzone afterwards instead.
+func (cal Calibration) apply(state *ControllerState, deadzone float32, radial bool) {
+	for _, name := range stickAxes {
+		v, dz := state.axis(name), deadzone
+		if ac, ok := cal[name]; ok {
+			scale := ac.Scale
+			if scale == 0 {
+				scale = 1
+			}
+			*v = float32(math.Max(-1, math.Min(1, float64((*v-ac.Offset)*scale))))
+			if ac.Deadzone > 0 {
+				dz = ac.Deadzone
+			}
+		}
+		if !radial && math.Abs(float64(*v)) < float64(dz) {
+			*v = 0
+		}
+	}
+}
+
+const defaultCalibrationWindow = 2 * time.Second
+
+// calibrationMargin is added to the largest resting deviation seen by
+// Calibrate so noise at the edge of it does not leak through.
+const calibrationMargin = 0.03
+
+// Calibrate measures the sticks at rest for window and returns offsets that
+// centre them, with deadzones just past the jitter seen meanwhile. The
+// sticks must not be touched while it runs. Scales are left at 1.
+func (c *Controller) Calibrate(ctx context.Context, window time.Duration) (Calibration, error) {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	var samples [][4]float32
+	for ctx.Err() == nil {
+		state, err := c.readReport(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return nil, err
+		}
+		if c.isInputReport(state.ReportID) {
+			samples = append(samples, [4]float32{c.raw.LEFTX, c.raw.LEFTY, c.raw.RIGHTX, c.raw.RIGHTY})
+		}
+	}
+	if len(samples) == 0 {
+		return nil, fmt.Errorf("no input reports in %v", window)
+	}
+
+	cal := make(Calibration)
+	for i, name := range stickAxes {
+		var sum float64
+		for _, s := range samples {
+			sum += float64(s[i])
+		}
+		mean := float32(sum / float64(len(samples)))
+
+		var spread float32
+		for _, s := range samples {
+			spread = max(spread, float32(math.Abs(float64(s[i]-mean))))
+		}
+		cal[name] = AxisCalibration{Offset: mean, Scale: 1, Deadzone: spread + calibrationMargin}
+	}
+	return cal, nil
+}
+
+const calibrationFileVersion = 1
+
+// calibrationFile holds one Calibration per controller serial number, so a
+// single file can serve several controllers.
+type calibrationFile struct {
+	Version     int                    `json:"version"`
+	Controllers map[string]Calibration `json:"controllers"`
+}
+
+func readCalibrationFile(path string) (*calibrationFile, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var f calibrationFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("parsing %s failed: %v", path, err)
+	}
+	if f.Version != calibrationFileVersion {
+		return nil, fmt.Errorf("unsupported calibration file version %d in %s", f.Version, path)
+	}
+	return &f, nil
+}
+
+// SaveCalibration stores the calibration in effect under this controller's
+// serial number, keeping the entries of other controllers in the file.
+func (c *Controller) SaveCalibration(path string) error {
+	if c.serial == "" {
+		return fmt.Errorf("controller has no serial number to key the calibration on")
+	}
+
+	f, err := readCalibrationFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		f, err = &calibrationFile{Version: calibrationFileVersion}, nil
+	}
+	if err != nil {
+		return err
+	}
+	if f.Controllers == nil {
+		f.Controllers = make(map[string]Calibration)
+	}
+	f.Controllers[c.serial] = c.cfg.Calibration
+
+	data, err := json.MarshalIndent(f, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// Write and rename so an interrupted save never truncates the file.
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".*")
+	if err != nil {
+		return fmt.Errorf("saving calibration failed: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return fmt.Errorf("saving calibration failed: %v", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("saving calibration failed: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("saving calibration failed: %v", err)
+	}
+	return nil
+}
+
+// LoadCalibration applies the entry for this controller's serial number.
+// A missing file or entry is reported as os.ErrNotExist.
+func (c *Controller) LoadCalibration(path string) error {
+	f, err := readCalibrationFile(path)
+	if err != nil {
+		return err
+	}
+	cal, ok := f.Controllers[c.serial]
+	if !ok || c.serial == "" {
+		return fmt.Errorf("no calibration for controller %q in %s: %w", c.serial, path, os.ErrNotExist)
+	}
+	c.SetCalibration(cal)
+	return nil
+}
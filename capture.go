@@ -0,0 +1,213 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sync"
+	"time"
+)
+
+// Capture files start with the magic "XBCP", a version byte and the
+// ReportFormat of the input reports, followed by records of little-endian
+// fields:
+//
+//	int64   capture time, Unix nanoseconds
+//	uint8   direction, 0 for controller to host, 1 for host to controller
+//	uint16  payload length
+//	[]byte  payload, the raw report or output packet
+//
+// Version 1 files have no format byte and hold GIP reports.
+const captureVersion = 2
+
+var captureMagic = []byte("XBCP")
+
+type CaptureDirection byte
+
+const (
+	CaptureIn CaptureDirection = iota
+	CaptureOut
+)
+
+func (d CaptureDirection) String() string {
+	switch d {
+	case CaptureIn:
+		return "in"
+	case CaptureOut:
+		return "out"
+	}
+	return fmt.Sprintf("CaptureDirection(%d)", int(d))
+}
+
+type CaptureRecord struct {
+	Time      time.Time
+	Direction CaptureDirection
+	Data      []byte
+}
+
+type CaptureWriter struct {
+	mu     sync.Mutex
+	w      io.Writer
+	failed bool
+}
+
+// NewCaptureWriter starts a capture of reports in format, which must be GIP
+// or HID; see ReportFormat.resolve for what Auto stands for.
+func NewCaptureWriter(w io.Writer, format ReportFormat) (*CaptureWriter, error) {
+	if format != ReportFormatGIP && format != ReportFormatHID {
+		return nil, fmt.Errorf("capture needs the gip or hid report format, got %s", format)
+	}
+	if _, err := w.Write(append(append([]byte{}, captureMagic...), captureVersion, byte(format))); err != nil {
+		return nil, fmt.Errorf("writing capture header failed: %v", err)
+	}
+	return &CaptureWriter{w: w}, nil
+}
+
+func (cw *CaptureWriter) Write(rec CaptureRecord) error {
+	buf := make([]byte, 11+len(rec.Data))
+	binary.LittleEndian.PutUint64(buf[0:8], uint64(rec.Time.UnixNano()))
+	buf[8] = byte(rec.Direction)
+	binary.LittleEndian.PutUint16(buf[9:11], uint16(len(rec.Data)))
+	copy(buf[11:], rec.Data)
+
+	cw.mu.Lock()
+	defer cw.mu.Unlock()
+	_, err := cw.w.Write(buf)
+	return err
+}
+
+// record is the controller-side hook: a failing capture is reported once
+// and then dropped so it never interrupts reading.
+func (cw *CaptureWriter) record(dir CaptureDirection, data []byte) {
+	if cw == nil {
+		return
+	}
+	if err := cw.Write(CaptureRecord{Time: time.Now(), Direction: dir, Data: data}); err != nil {
+		cw.mu.Lock()
+		defer cw.mu.Unlock()
+		if !cw.failed {
+			log.Printf("Capture stopped: %v", err)
+			cw.failed = true
+		}
+	}
+}
+
+type CaptureReader struct {
+	r      *bufio.Reader
+	format ReportFormat
+}
+
+func NewCaptureReader(r io.Reader) (*CaptureReader, error) {
+	br := bufio.NewReader(r)
+	header := make([]byte, len(captureMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, fmt.Errorf("reading capture header failed: %v", err)
+	}
+	if string(header[:len(captureMagic)]) != string(captureMagic) {
+		return nil, fmt.Errorf("not a capture file")
+	}
+	cr := &CaptureReader{r: br, format: ReportFormatGIP}
+	switch v := header[len(captureMagic)]; v {
+	case 1:
+	case captureVersion:
+		b, err := br.ReadByte()
+		if err != nil {
+			return nil, fmt.Errorf("reading capture header failed: %v", err)
+		}
+		cr.format = ReportFormat(b)
+		if cr.format != ReportFormatGIP && cr.format != ReportFormatHID {
+			return nil, fmt.Errorf("unknown capture report format %d", b)
+		}
+	default:
+		return nil, fmt.Errorf("unsupported capture version %d", v)
+	}
+	return cr, nil
+}
+
+// Format is the report format the captured input reports are in.
+func (cr *CaptureReader) Format() ReportFormat {
+	return cr.format
+}
+
+// Next returns io.EOF once the capture ends cleanly.
+func (cr *CaptureReader) Next() (CaptureRecord, error) {
+	head := make([]byte, 11)
+	if _, err := io.ReadFull(cr.r, head); err != nil {
+		if errors.Is(err, io.EOF) {
+			return CaptureRecord{}, io.EOF
+		}
+		return CaptureRecord{}, fmt.Errorf("reading capture record failed: %v", err)
+	}
+
+	rec := CaptureRecord{
+		Time:      time.Unix(0, int64(binary.LittleEndian.Uint64(head[0:8]))),
+		Direction: CaptureDirection(head[8]),
+		Data:      make([]byte, binary.LittleEndian.Uint16(head[9:11])),
+	}
+	if _, err := io.ReadFull(cr.r, rec.Data); err != nil {
+		return CaptureRecord{}, fmt.Errorf("reading capture payload failed: %v", err)
+	}
+	return rec, nil
+}
+
+// replayCapture feeds the captured input reports back through the decoder
+// for their format, with the deadzones applied as readReport applies them,
+// and logs the resulting changes, paced by the recorded timestamps.
+func replayCapture(path string, layout ButtonLayout, deadzone, triggerDeadzone float32) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("opening capture failed: %v", err)
+	}
+	defer f.Close()
+
+	cr, err := NewCaptureReader(f)
+	if err != nil {
+		return err
+	}
+
+	var state ControllerState
+	var last *ControllerState
+	var prev time.Time
+	guideReport := false
+	for {
+		rec, err := cr.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		if !prev.IsZero() {
+			time.Sleep(rec.Time.Sub(prev))
+		}
+		prev = rec.Time
+
+		if rec.Direction == CaptureOut {
+			log.Printf("Host sent % x", rec.Data)
+			continue
+		}
+		if cr.Format() == ReportFormatHID {
+			if len(rec.Data) > 0 && rec.Data[0] == reportBluetoothGuide {
+				guideReport = true
+			}
+			err = updateBluetoothReport(&state, rec.Data, 0, guideReport)
+		} else {
+			err = updateReport(&state, rec.Data, layout, 0)
+		}
+		if err != nil {
+			log.Printf("Bad captured report: %v", err)
+			continue
+		}
+
+		current := state
+		applyDeadzone(&current, deadzone)
+		applyTriggerDeadzone(&current, triggerDeadzone)
+		logStateChanges(&current, Diff(&current, last))
+		last = &current
+	}
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCaptureRoundTrip(t *testing.T) {
+	recs := []CaptureRecord{
+		{Time: time.Unix(5, 6), Direction: CaptureOut, Data: []byte{0x05, 0x20}},
+		{Time: time.Unix(5, 2000006), Direction: CaptureIn, Data: pressReport("A")},
+		{Time: time.Unix(6, 0), Direction: CaptureIn, Data: []byte{}},
+	}
+
+	var buf bytes.Buffer
+	w, err := NewCaptureWriter(&buf, ReportFormatGIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, rec := range recs {
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	r, err := NewCaptureReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, want := range recs {
+		got, err := r.Next()
+		if err != nil {
+			t.Fatalf("record %d: %v", i, err)
+		}
+		if !got.Time.Equal(want.Time) || got.Direction != want.Direction || !bytes.Equal(got.Data, want.Data) {
+			t.Fatalf("record %d = %+v, want %+v", i, got, want)
+		}
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("after the last record: got %v, want io.EOF", err)
+	}
+}
+
+func TestCaptureRecordsControllerTraffic(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewCaptureWriter(&buf, ReportFormatGIP)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := &fakeTransport{}
+	ft.queue(pressReport("B"))
+	c, err := NewFromTransport(ft, WithCapture(w))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Initialize(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.ReadState(); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := NewCaptureReader(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []CaptureDirection{CaptureOut, CaptureIn} {
+		rec, err := r.Next()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if rec.Direction != want {
+			t.Fatalf("captured %v, want %v", rec.Direction, want)
+		}
+		if want == CaptureIn {
+			state, err := DecodeReport(rec.Data, len(rec.Data))
+			if err != nil || !state.B {
+				t.Fatalf("replayed report: %+v, %v", state, err)
+			}
+		}
+	}
+}
+
+func TestCaptureReplayHID(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bt.cap")
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	w, err := NewCaptureWriter(f, ReportFormatHID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pressed := bluetoothInput()
+	pressed[14] |= 0x01 // A
+	at := time.Now()
+	for i, report := range [][]byte{bluetoothInput(), pressed, {reportBluetoothGuide, 0x01}, bluetoothInput()} {
+		rec := CaptureRecord{Time: at.Add(time.Duration(i) * time.Millisecond), Direction: CaptureIn, Data: report}
+		if err := w.Write(rec); err != nil {
+			t.Fatal(err)
+		}
+	}
+	f.Close()
+
+	f, err = os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r, err := NewCaptureReader(f)
+	f.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format() != ReportFormatHID {
+		t.Fatalf("capture format = %s, want hid", r.Format())
+	}
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+	if err := replayCapture(path, StandardLayout, 0.1, DefaultConfig().TriggerDeadzone); err != nil {
+		t.Fatal(err)
+	}
+	out := logs.String()
+	for _, want := range []string{"A pressed", "GUIDE pressed", "A released"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("replay log is missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "GUIDE released") || strings.Contains(out, "Bad captured report") {
+		t.Errorf("replay did not decode like the live path:\n%s", out)
+	}
+}
+
+func TestCaptureReaderVersion1(t *testing.T) {
+	r, err := NewCaptureReader(bytes.NewReader(append([]byte("XBCP"), 1)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Format() != ReportFormatGIP {
+		t.Fatalf("version 1 capture format = %s, want gip", r.Format())
+	}
+	if _, err := r.Next(); err != io.EOF {
+		t.Fatalf("empty capture: got %v, want io.EOF", err)
+	}
+}
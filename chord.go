@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type Chord []string
+
+func ParseChord(spec string) (Chord, error) {
+	var chord Chord
+	var s ControllerState
+	for _, name := range strings.Split(spec, "+") {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if s.button(name) == nil {
+			return nil, fmt.Errorf("unknown button %q in chord %q", name, spec)
+		}
+		chord = append(chord, name)
+	}
+	if len(chord) == 0 {
+		return nil, fmt.Errorf("empty chord")
+	}
+	return chord, nil
+}
+
+func (ch Chord) String() string {
+	return strings.Join(ch, "+")
+}
+
+func (ch Chord) Held(s *ControllerState) bool {
+	for _, name := range ch {
+		if !*s.button(name) {
+			return false
+		}
+	}
+	return true
+}
+
+// Completed reports whether this update pressed the last missing button of
+// the chord, so a held chord fires once rather than on every poll.
+func (ch Chord) Completed(s *ControllerState, diff StateDiff) bool {
+	if !ch.Held(s) {
+		return false
+	}
+	for _, pressed := range diff.Pressed {
+		for _, name := range ch {
+			if pressed == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// RecordChord waits for the user to press a combination and returns the
+// buttons that were held together when the first of them was let go. Since
+// nothing is released before that point, this is the largest set held at
+// once. Buttons already held when recording starts count as part of it.
+func (c *Controller) RecordChord(ctx context.Context) ([]string, error) {
+	var held []string
+	for {
+		state, diff, err := c.readStateDiff(ctx)
+		if err != nil {
+			return nil, err
+		}
+		for _, name := range diff.Released {
+			if !isTrigger(name) && len(held) > 0 {
+				return held, nil
+			}
+		}
+		held = state.PressedButtons()
+		time.Sleep(c.PollInterval())
+	}
+}
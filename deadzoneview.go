@@ -0,0 +1,44 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math"
+)
+
+// deadzoneView prints each stick before and after the deadzone and the rest
+// of the pipeline, to help pick a -deadzone value. The raw values are the
+// controller's own decode of the same report, as ReadRawState returns it.
+type deadzoneView struct {
+	printed [4]float32
+}
+
+// deadzoneViewStep skips printing until a raw axis moves by at least this
+// much, so a resting stick does not flood the log with identical lines.
+const deadzoneViewStep = 0.005
+
+func (v *deadzoneView) print(c *Controller, state *ControllerState) {
+	raw := [4]float32{c.raw.LEFTX, c.raw.LEFTY, c.raw.RIGHTX, c.raw.RIGHTY}
+	moved := false
+	for i := range raw {
+		moved = moved || math.Abs(float64(raw[i]-v.printed[i])) >= deadzoneViewStep
+	}
+	if !moved {
+		return
+	}
+	v.printed = raw
+
+	log.Printf("Left %s   Right %s",
+		deadzoneViewStick(raw[0], raw[1], state.LEFTX, state.LEFTY),
+		deadzoneViewStick(raw[2], raw[3], state.RIGHTX, state.RIGHTY))
+}
+
+func deadzoneViewStick(rawX, rawY, x, y float32) string {
+	s := fmt.Sprintf("raw %+.3f,%+.3f |%.3f| -> %+.3f,%+.3f", rawX, rawY, math.Hypot(float64(rawX), float64(rawY)), x, y)
+	if x == 0 && y == 0 && (rawX != 0 || rawY != 0) {
+		s += " (deadzone)"
+	} else {
+		s += "           "
+	}
+	return s
+}
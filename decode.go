@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+)
+
+func DecodeReport(buf []byte, n int) (*ControllerState, error) {
+	state := &ControllerState{}
+	if err := UpdateState(state, buf, n); err != nil {
+		return nil, err
+	}
+	return state, nil
+}
+
+// UpdateState applies one report to an existing state, touching only the
+// fields that report carries: the 0x07 guide report sets GUIDE alone and the
+// 0x20 input report sets everything else. Unknown reports change nothing
+// but ReportID. A Controller merges Bluetooth HID reports the same way.
+func UpdateState(s *ControllerState, buf []byte, n int) error {
+	if n < 0 || n > len(buf) {
+		return fmt.Errorf("invalid report length %d for %d byte buffer", n, len(buf))
+	}
+	if err := updateReport(s, buf[:n], StandardLayout, DefaultConfig().Deadzone); err != nil {
+		return err
+	}
+	applyTriggerDeadzone(s, DefaultConfig().TriggerDeadzone)
+	return nil
+}
+
+const (
+	reportInput = 0x20
+	reportGuide = 0x07
+)
+
+// ReportFormat selects how input reports are parsed: GIP, with input in
+// report 0x20 as USB controllers normally send it, or HID, with input in
+// report 0x01 as over Bluetooth and from some firmware in HID mode over USB.
+// ReportFormatAuto uses HID for Bluetooth transports and GIP otherwise.
+type ReportFormat int
+
+const (
+	ReportFormatAuto ReportFormat = iota
+	ReportFormatGIP
+	ReportFormatHID
+)
+
+func (f ReportFormat) String() string {
+	switch f {
+	case ReportFormatAuto:
+		return "auto"
+	case ReportFormatGIP:
+		return "gip"
+	case ReportFormatHID:
+		return "hid"
+	}
+	return "unknown"
+}
+
+func ParseReportFormat(s string) (ReportFormat, error) {
+	switch s {
+	case "auto":
+		return ReportFormatAuto, nil
+	case "gip":
+		return ReportFormatGIP, nil
+	case "hid":
+		return ReportFormatHID, nil
+	}
+	return 0, fmt.Errorf("unknown report format %q, expected auto, gip or hid", s)
+}
+
+// resolve picks the concrete format Auto stands for on transport t.
+func (f ReportFormat) resolve(t TransportKind) ReportFormat {
+	if f != ReportFormatAuto {
+		return f
+	}
+	if t == TransportBluetooth {
+		return ReportFormatHID
+	}
+	return ReportFormatGIP
+}
+
+// hidReports reports whether input reports use the HID layout. GIP acks are
+// only sent for GIP reports.
+func (c *Controller) hidReports() bool {
+	return c.cfg.ReportFormat.resolve(c.info.Transport) == ReportFormatHID
+}
+
+const (
+	triggerRange = 1023.0
+	stickRange   = 32768.0
+)
+
+var minReportLen = map[byte]int{
+	reportInput: 17,
+	reportGuide: 5,
+}
+
+func updateReport(state *ControllerState, buf []byte, layout ButtonLayout, deadzone float32) error {
+	n := len(buf)
+	if n == 0 {
+		return fmt.Errorf("%w: %d bytes", ErrShortRead, n)
+	}
+	if min, ok := minReportLen[buf[0]]; ok && n < min {
+		return fmt.Errorf("%w: %d bytes for report 0x%02x, need %d", ErrShortRead, n, buf[0], min)
+	}
+
+	state.ReportID = buf[0]
+
+	switch buf[0] {
+	case reportInput:
+		layout.apply(state, buf)
+		lt := binary.LittleEndian.Uint16(buf[5:7])
+		rt := binary.LittleEndian.Uint16(buf[7:9])
+		state.LT = float32(lt) / triggerRange
+		state.RT = float32(rt) / triggerRange
+		lx := int16(binary.LittleEndian.Uint16(buf[9:11]))
+		ly := int16(binary.LittleEndian.Uint16(buf[11:13]))
+		rx := int16(binary.LittleEndian.Uint16(buf[13:15]))
+		ry := int16(binary.LittleEndian.Uint16(buf[15:17]))
+		state.LEFTX = float32(lx) / stickRange
+		state.LEFTY = float32(ly) / stickRange
+		state.RIGHTX = float32(rx) / stickRange
+		state.RIGHTY = float32(ry) / stickRange
+
+		applyDeadzone(state, deadzone)
+
+		state.Extra = nil
+		if n > minReportLen[reportInput] {
+			state.Extra = append([]byte(nil), buf[minReportLen[reportInput]:]...)
+		}
+
+	case reportGuide:
+		// Byte 2 is the GIP sequence number; the key state follows the
+		// one-byte payload length.
+		state.GUIDE = buf[4]&0x01 != 0
+	}
+
+	return nil
+}
+
+func applyDeadzone(state *ControllerState, deadzone float32) {
+	dz := float64(deadzone)
+	for _, v := range []*float32{&state.LEFTX, &state.LEFTY, &state.RIGHTX, &state.RIGHTY} {
+		if math.Abs(float64(*v)) < dz {
+			*v = 0
+		}
+	}
+}
+
+// applyTriggerDeadzone snaps a barely pulled trigger to 0. The triggers get
+// their own threshold since they rest at 0 rather than centred and need far
+// less than the sticks.
+func applyTriggerDeadzone(state *ControllerState, deadzone float32) {
+	for _, v := range []*float32{&state.LT, &state.RT} {
+		if *v < deadzone {
+			*v = 0
+		}
+	}
+}
+
+// StickVectorDeadzone applies a scaled radial deadzone: vectors shorter than
+// deadzone become zero, and longer ones keep their exact angle while the
+// magnitude is remapped from [deadzone, 1] onto [0, 1], so leaving the
+// deadzone ramps up from zero instead of jumping.
+func StickVectorDeadzone(x, y, deadzone float32) (float32, float32) {
+	m := math.Hypot(float64(x), float64(y))
+	dz := float64(deadzone)
+	if m <= dz {
+		return 0, 0
+	}
+	scaled := (math.Min(m, 1) - dz) / (1 - dz)
+	return float32(float64(x) / m * scaled), float32(float64(y) / m * scaled)
+}
+
+func applyRadialDeadzone(state *ControllerState, deadzone float32) {
+	state.LEFTX, state.LEFTY = StickVectorDeadzone(state.LEFTX, state.LEFTY, deadzone)
+	state.RIGHTX, state.RIGHTY = StickVectorDeadzone(state.RIGHTX, state.RIGHTY, deadzone)
+}
+
+// applySensitivity multiplies each stick by its linear sensitivity and
+// clamps the axes back to [-1, 1].
+func applySensitivity(state *ControllerState, left, right float32) {
+	scale := func(v *float32, k float32) {
+		*v *= k
+		if *v > 1 {
+			*v = 1
+		} else if *v < -1 {
+			*v = -1
+		}
+	}
+	if left != 1 {
+		scale(&state.LEFTX, left)
+		scale(&state.LEFTY, left)
+	}
+	if right != 1 {
+		scale(&state.RIGHTX, right)
+		scale(&state.RIGHTY, right)
+	}
+}
+
+// clampSticks scales each stick back onto the unit circle when its corner
+// deflection exceeds magnitude 1, keeping the direction.
+func clampSticks(state *ControllerState) {
+	for _, stick := range [][2]*float32{{&state.LEFTX, &state.LEFTY}, {&state.RIGHTX, &state.RIGHTY}} {
+		x, y := float64(*stick[0]), float64(*stick[1])
+		if m := math.Hypot(x, y); m > 1 {
+			*stick[0] = float32(x / m)
+			*stick[1] = float32(y / m)
+		}
+	}
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"math"
+	"testing"
+)
+
+func inputReport(n int) []byte {
+	buf := make([]byte, n)
+	buf[0] = reportInput
+	return buf
+}
+
+func FuzzDecodeReport(f *testing.F) {
+	f.Add(inputReport(17), 17)
+	f.Add(inputReport(16), 16)
+	f.Add(inputReport(1), 1)
+	f.Add([]byte{reportGuide, 0x20, 0x01, 0x02, 0x01}, 5)
+	f.Add([]byte{reportGuide, 0x20, 0x01, 0x02}, 4)
+	f.Add([]byte{reportGuide}, 1)
+	f.Add([]byte{}, 0)
+
+	f.Fuzz(func(t *testing.T, buf []byte, n int) {
+		state, err := DecodeReport(buf, n)
+		if err != nil {
+			return
+		}
+		if min, ok := minReportLen[buf[0]]; ok && n < min {
+			t.Fatalf("report 0x%02x of %d bytes decoded, need %d", buf[0], n, min)
+		}
+		if state.ReportID != buf[0] {
+			t.Fatalf("ReportID = 0x%02x, want 0x%02x", state.ReportID, buf[0])
+		}
+	})
+}
+
+func TestDecodeReportShortRead(t *testing.T) {
+	if _, err := DecodeReport(inputReport(16), 16); !errors.Is(err, ErrShortRead) {
+		t.Fatalf("16-byte input report: got %v, want ErrShortRead", err)
+	}
+	if _, err := DecodeReport(inputReport(17), 17); err != nil {
+		t.Fatalf("17-byte input report: %v", err)
+	}
+}
+
+func TestApplyDeadzone(t *testing.T) {
+	const dz = 0.1
+	tests := []struct {
+		in, want float32
+	}{
+		{0, 0},
+		{0.05, 0},
+		{-0.05, 0},
+		{0.0999, 0},
+		{dz, dz},
+		{-dz, -dz},
+		{0.1001, 0.1001},
+		{-0.1001, -0.1001},
+		{1, 1},
+		{-1, -1},
+	}
+	for _, tt := range tests {
+		for _, name := range stickAxes {
+			var s ControllerState
+			*s.axis(name) = tt.in
+			applyDeadzone(&s, dz)
+			if got := *s.axis(name); got != tt.want {
+				t.Errorf("%s = %v with deadzone %v: got %v, want %v", name, tt.in, dz, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestClampSticks(t *testing.T) {
+	tests := []struct {
+		x, y, wantX, wantY float32
+	}{
+		{1, 1, math.Sqrt2 / 2, math.Sqrt2 / 2},
+		{-1, 1, -math.Sqrt2 / 2, math.Sqrt2 / 2},
+		{-1, -1, -math.Sqrt2 / 2, -math.Sqrt2 / 2},
+		{0.6, 0.8, 0.6, 0.8},
+		{0.5, -0.5, 0.5, -0.5},
+		{1, 0, 1, 0},
+		{0, 0, 0, 0},
+	}
+	for _, tt := range tests {
+		s := ControllerState{LEFTX: tt.x, LEFTY: tt.y, RIGHTX: tt.x, RIGHTY: tt.y}
+		clampSticks(&s)
+		if s.LEFTX != tt.wantX || s.LEFTY != tt.wantY || s.RIGHTX != tt.wantX || s.RIGHTY != tt.wantY {
+			t.Errorf("clamp (%v, %v) = left (%v, %v) right (%v, %v), want (%v, %v)",
+				tt.x, tt.y, s.LEFTX, s.LEFTY, s.RIGHTX, s.RIGHTY, tt.wantX, tt.wantY)
+		}
+	}
+}
+
+func TestStickVectorDeadzoneKeepsAngle(t *testing.T) {
+	const dz = 0.2
+	for _, deg := range []float64{0, 30, 45, 90, 135, 180, 225, 270, 315, 350} {
+		for _, m := range []float64{0.3, 0.6, 1} {
+			rad := deg * math.Pi / 180
+			x, y := float32(m*math.Cos(rad)), float32(m*math.Sin(rad))
+			gx, gy := StickVectorDeadzone(x, y, dz)
+
+			wantM := (m - dz) / (1 - dz)
+			if gotM := math.Hypot(float64(gx), float64(gy)); math.Abs(gotM-wantM) > 1e-6 {
+				t.Errorf("%v° magnitude %v: got magnitude %v, want %v", deg, m, gotM, wantM)
+			}
+			gotDeg := math.Atan2(float64(gy), float64(gx)) * 180 / math.Pi
+			if diff := math.Mod(gotDeg-deg+540, 360) - 180; math.Abs(diff) > 1e-4 {
+				t.Errorf("%v° magnitude %v: angle moved to %v°", deg, m, gotDeg)
+			}
+		}
+	}
+}
+
+func TestStickVectorDeadzoneInside(t *testing.T) {
+	for _, v := range [][2]float32{{0, 0}, {0.1, 0}, {0, -0.2}, {0.1, 0.1}} {
+		if x, y := StickVectorDeadzone(v[0], v[1], 0.2); x != 0 || y != 0 {
+			t.Errorf("(%v, %v) inside the deadzone = (%v, %v), want (0, 0)", v[0], v[1], x, y)
+		}
+	}
+	if x, y := StickVectorDeadzone(0, 0, 0); x != 0 || y != 0 {
+		t.Errorf("zero vector with no deadzone = (%v, %v), want (0, 0)", x, y)
+	}
+}
+
+func TestDecodeScaling(t *testing.T) {
+	sticks := []struct {
+		raw  int16
+		want float32
+	}{
+		{-32768, -1},
+		{-16384, -0.5},
+		{0, 0},
+		{16384, 0.5},
+		{32767, 32767.0 / 32768},
+	}
+	for _, tt := range sticks {
+		buf := inputReport(17)
+		for off := 9; off < 17; off += 2 {
+			binary.LittleEndian.PutUint16(buf[off:], uint16(tt.raw))
+		}
+		s, err := DecodeReport(buf, len(buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, name := range stickAxes {
+			if got := *s.axis(name); got != tt.want {
+				t.Errorf("%s raw %d = %v, want %v", name, tt.raw, got, tt.want)
+			}
+		}
+	}
+
+	triggers := []struct {
+		raw  uint16
+		want float32
+	}{
+		{0, 0},
+		{512, 512.0 / 1023},
+		{1023, 1},
+	}
+	for _, tt := range triggers {
+		buf := inputReport(17)
+		binary.LittleEndian.PutUint16(buf[5:], tt.raw)
+		binary.LittleEndian.PutUint16(buf[7:], tt.raw)
+		s, err := DecodeReport(buf, len(buf))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if s.LT != tt.want || s.RT != tt.want {
+			t.Errorf("triggers raw %d = %v, %v, want %v", tt.raw, s.LT, s.RT, tt.want)
+		}
+	}
+}
+
+func TestApplyTriggerDeadzone(t *testing.T) {
+	dz := DefaultConfig().TriggerDeadzone
+	if dz != 0.02 {
+		t.Fatalf("default trigger deadzone = %v, want 0.02", dz)
+	}
+	tests := []struct {
+		in, want float32
+	}{
+		{0, 0},
+		{0.01, 0},
+		{0.0199, 0},
+		{dz, dz},
+		{0.0201, 0.0201},
+		{0.5, 0.5},
+		{1, 1},
+	}
+	for _, tt := range tests {
+		s := ControllerState{LT: tt.in, RT: tt.in}
+		applyTriggerDeadzone(&s, dz)
+		if s.LT != tt.want || s.RT != tt.want {
+			t.Errorf("triggers %v = %v, %v, want %v", tt.in, s.LT, s.RT, tt.want)
+		}
+	}
+
+	// Raw 20 of 1023 sits just below 0.02 and must decode as released.
+	buf := inputReport(17)
+	binary.LittleEndian.PutUint16(buf[5:], 20)
+	binary.LittleEndian.PutUint16(buf[7:], 21)
+	s, err := DecodeReport(buf, len(buf))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if s.LT != 0 || s.RT != 21.0/1023 {
+		t.Errorf("decoded triggers raw 20, 21 = %v, %v, want 0, %v", s.LT, s.RT, float32(21.0/1023))
+	}
+}
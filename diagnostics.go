@@ -0,0 +1,53 @@
+package main
+
+import (
+	"log"
+	"math"
+	"strings"
+)
+
+func WithDiagnostics(enabled bool) Option {
+	return func(c *Controller) {
+		c.cfg.Diagnostics = enabled
+	}
+}
+
+// pinnedAxis is close enough to full deflection to count as pinned; sticks
+// sit in a round gate, so both axes of one stick cannot be there at once.
+const pinnedAxis = 0.999
+
+// impossibleInputs lists combinations the hardware cannot produce, which
+// point at a decode error, a wrong -layout or a failing controller.
+func impossibleInputs(s *ControllerState) []string {
+	var found []string
+	if s.UP && s.DOWN {
+		found = append(found, "UP+DOWN")
+	}
+	if s.LEFT && s.RIGHT {
+		found = append(found, "LEFT+RIGHT")
+	}
+	pinned := func(x, y float32) bool {
+		return math.Abs(float64(x)) >= pinnedAxis && math.Abs(float64(y)) >= pinnedAxis
+	}
+	if pinned(s.LEFTX, s.LEFTY) {
+		found = append(found, "left stick pinned on both axes")
+	}
+	if pinned(s.RIGHTX, s.RIGHTY) {
+		found = append(found, "right stick pinned on both axes")
+	}
+	return found
+}
+
+// checkInputs runs on the decoded report before remapping or the stick
+// d-pad, which may legitimately combine opposite directions. A warning is
+// logged when the set of problems changes rather than on every report.
+func (c *Controller) checkInputs(report []byte) {
+	problems := strings.Join(impossibleInputs(&c.raw), ", ")
+	if problems == c.impossible {
+		return
+	}
+	c.impossible = problems
+	if problems != "" {
+		log.Printf("Warning: impossible input (%s) in report % x; check -layout or the controller", problems, report)
+	}
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"math/bits"
+	"strings"
+)
+
+const analogThreshold = 0.1
+
+// Pressed and Released name buttons, and LT and RT when LTPressed or
+// RTPressed changes.
+type StateDiff struct {
+	Pressed    []string
+	Released   []string
+	LeftStick  bool
+	RightStick bool
+	Triggers   bool
+
+	// Tapped and LongPressed classify the releases of WithLongPress
+	// buttons; both are also listed in Released.
+	Tapped      []string
+	LongPressed []string
+}
+
+func (d StateDiff) Empty() bool {
+	return len(d.Pressed) == 0 && len(d.Released) == 0 && !d.LeftStick && !d.RightStick && !d.Triggers
+}
+
+type AnalogGroups struct {
+	LeftStick  bool
+	RightStick bool
+	Triggers   bool
+}
+
+var AllAnalogGroups = AnalogGroups{LeftStick: true, RightStick: true, Triggers: true}
+
+func ParseAnalogGroups(spec string) (AnalogGroups, error) {
+	var g AnalogGroups
+	for _, name := range strings.Split(spec, ",") {
+		switch strings.ToLower(strings.TrimSpace(name)) {
+		case "all":
+			g = AllAnalogGroups
+		case "none", "":
+		case "left", "ls":
+			g.LeftStick = true
+		case "right", "rs":
+			g.RightStick = true
+		case "triggers":
+			g.Triggers = true
+		default:
+			return g, fmt.Errorf("unknown analog group %q, expected left, right, triggers, all or none", name)
+		}
+	}
+	return g, nil
+}
+
+func (g AnalogGroups) filter(diff StateDiff) StateDiff {
+	diff.LeftStick = diff.LeftStick && g.LeftStick
+	diff.RightStick = diff.RightStick && g.RightStick
+	diff.Triggers = diff.Triggers && g.Triggers
+	return diff
+}
+
+func Diff(current, last *ControllerState) StateDiff {
+	var diff StateDiff
+	if current == nil || last == nil {
+		return diff
+	}
+
+	cur := current.ButtonMask()
+	for changed := cur ^ last.ButtonMask(); changed != 0; changed &= changed - 1 {
+		i := bits.TrailingZeros32(changed)
+		if cur&(1<<i) != 0 {
+			diff.Pressed = append(diff.Pressed, ButtonNames[i])
+		} else {
+			diff.Released = append(diff.Released, ButtonNames[i])
+		}
+	}
+	trigger := func(name string, pressed, was bool) {
+		if pressed && !was {
+			diff.Pressed = append(diff.Pressed, name)
+		} else if !pressed && was {
+			diff.Released = append(diff.Released, name)
+		}
+	}
+	trigger("LT", current.LTPressed, last.LTPressed)
+	trigger("RT", current.RTPressed, last.RTPressed)
+
+	diff.LeftStick = math.Abs(float64(current.LEFTX-last.LEFTX)) > analogThreshold ||
+		math.Abs(float64(current.LEFTY-last.LEFTY)) > analogThreshold
+	diff.RightStick = math.Abs(float64(current.RIGHTX-last.RIGHTX)) > analogThreshold ||
+		math.Abs(float64(current.RIGHTY-last.RIGHTY)) > analogThreshold
+	diff.Triggers = math.Abs(float64(current.LT-last.LT)) > analogThreshold ||
+		math.Abs(float64(current.RT-last.RT)) > analogThreshold
+
+	return diff
+}
+
+func (c *Controller) ReadStateDiff() (*ControllerState, StateDiff, error) {
+	return c.readStateDiff(c.ctx)
+}
+
+func (c *Controller) readStateDiff(ctx context.Context) (*ControllerState, StateDiff, error) {
+	state, err := c.readState(ctx)
+	if err != nil {
+		return nil, StateDiff{}, err
+	}
+
+	if c.last != nil {
+		prev := *c.last
+		prev.LastState = nil
+		state.LastState = &prev
+	}
+	c.last = state
+
+	return state, Diff(state, state.LastState), nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"encoding/binary"
+	"reflect"
+	"testing"
+)
+
+// triggerReport is an idle input report with LT pulled to raw.
+func triggerReport(raw uint16) []byte {
+	buf := pressReport()
+	binary.LittleEndian.PutUint16(buf[5:7], raw)
+	return buf
+}
+
+func TestTriggerPressEdges(t *testing.T) {
+	ft := &fakeTransport{}
+	ft.queue(triggerReport(0), triggerReport(700), triggerReport(450), triggerReport(300), triggerReport(0))
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithTriggerThresholds(0.6, 0.4))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	want := [][]Event{
+		nil,
+		{{Type: EventPress, Button: "LT"}},
+		nil, // 0.44 is still above the release threshold
+		{{Type: EventRelease, Button: "LT"}},
+		nil,
+	}
+	for i, w := range want {
+		_, events, err := c.Poll()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []Event
+		for _, ev := range events {
+			if ev.Type == EventPress || ev.Type == EventRelease {
+				got = append(got, Event{Type: ev.Type, Button: ev.Button})
+			}
+		}
+		if !reflect.DeepEqual(got, w) {
+			t.Errorf("report %d: button events %+v, want %+v", i, got, w)
+		}
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"fmt"
+	"math"
+)
+
+type stickDpad struct {
+	ways int
+	push hysteresis
+}
+
+func newStickDpad(ways int, threshold, release float32) (*stickDpad, error) {
+	if ways != 4 && ways != 8 {
+		return nil, fmt.Errorf("stick dpad must be 4 or 8 way, got %d", ways)
+	}
+	push, err := newHysteresis("stick dpad", threshold, release)
+	if err != nil {
+		return nil, err
+	}
+	return &stickDpad{ways: ways, push: push}, nil
+}
+
+// apply snaps the left stick angle to the nearest of 4 or 8 directions and
+// ORs the result into the d-pad while the stick is pushed: from when it
+// reaches the threshold until it falls back below the release threshold.
+func (d *stickDpad) apply(s *ControllerState) {
+	x, y := float64(s.LEFTX), float64(s.LEFTY)
+	if !d.push.update(float32(math.Hypot(x, y)), s.Time) {
+		return
+	}
+
+	sector := 2 * math.Pi / float64(d.ways)
+	angle := math.Round(math.Atan2(y, x)/sector) * sector
+
+	const eps = 1e-9
+	dx, dy := math.Cos(angle), math.Sin(angle)
+	s.RIGHT = s.RIGHT || dx > eps
+	s.LEFT = s.LEFT || dx < -eps
+	s.UP = s.UP || dy > eps
+	s.DOWN = s.DOWN || dy < -eps
+}
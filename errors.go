@@ -0,0 +1,36 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/google/gousb"
+)
+
+var (
+	ErrNoDevice     = errors.New("no compatible Xbox controller found")
+	ErrPermission   = errors.New("permission denied opening controller")
+	ErrDisconnected = errors.New("controller disconnected")
+	ErrShortRead    = errors.New("short read")
+	ErrReadOnly     = errors.New("controller is opened read-only")
+	ErrClosed       = errors.New("controller is closed")
+	ErrBusy         = errors.New("controller is in use by another program or driver")
+	ErrCannotReopen = errors.New("controller cannot be reopened")
+)
+
+// usbError tags libusb and hidraw failures with the matching sentinel while
+// keeping the original error in the chain.
+func usbError(err error) error {
+	switch {
+	case err == nil:
+		return nil
+	case errors.Is(err, gousb.ErrorNoDevice), errors.Is(err, gousb.TransferNoDevice):
+		return fmt.Errorf("%w: %w", ErrDisconnected, err)
+	case errors.Is(err, gousb.ErrorAccess), errors.Is(err, os.ErrPermission):
+		return fmt.Errorf("%w: %w", ErrPermission, err)
+	case errors.Is(err, gousb.ErrorBusy):
+		return fmt.Errorf("%w: %w", ErrBusy, err)
+	}
+	return err
+}
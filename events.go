@@ -0,0 +1,427 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"math"
+	"sync"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+type EventType int
+
+const (
+	EventPress EventType = iota
+	EventRelease
+	EventDisconnect
+	EventAxis
+	EventTap
+	EventLongPress
+)
+
+func (t EventType) String() string {
+	switch t {
+	case EventPress:
+		return "press"
+	case EventRelease:
+		return "release"
+	case EventDisconnect:
+		return "disconnect"
+	case EventAxis:
+		return "axis"
+	case EventTap:
+		return "tap"
+	case EventLongPress:
+		return "long press"
+	}
+	return fmt.Sprintf("EventType(%d)", int(t))
+}
+
+type Edge int
+
+const (
+	EdgeBoth Edge = iota
+	EdgeRising
+	EdgeFalling
+)
+
+func (e Edge) String() string {
+	switch e {
+	case EdgeBoth:
+		return "both"
+	case EdgeRising:
+		return "rising"
+	case EdgeFalling:
+		return "falling"
+	}
+	return fmt.Sprintf("Edge(%d)", int(e))
+}
+
+func ParseEdge(s string) (Edge, error) {
+	switch s {
+	case "both":
+		return EdgeBoth, nil
+	case "rising", "press":
+		return EdgeRising, nil
+	case "falling", "release":
+		return EdgeFalling, nil
+	}
+	return 0, fmt.Errorf("unknown edge %q, expected both, rising or falling", s)
+}
+
+func (e Edge) allows(t EventType) bool {
+	switch e {
+	case EdgeRising:
+		return t != EventRelease
+	case EdgeFalling:
+		return t != EventPress
+	}
+	return true
+}
+
+func (e Edge) filter(diff StateDiff) StateDiff {
+	if !e.allows(EventPress) {
+		diff.Pressed = nil
+	}
+	if !e.allows(EventRelease) {
+		diff.Released = nil
+	}
+	return diff
+}
+
+// Axis events name the group that moved in Axis ("left", "right" or
+// "triggers") with its new vector in X, Y (LT, RT for triggers) and Delta,
+// the distance it moved since the previous read.
+type Event struct {
+	Type   EventType
+	Button string
+	State  *ControllerState
+	Time   time.Time
+	Player int
+	Serial string
+	Macro  string
+
+	Axis  string
+	X, Y  float32
+	Delta float32
+}
+
+func (c *Controller) Player() int {
+	return c.player
+}
+
+func (c *Controller) events(state *ControllerState, diff StateDiff, now time.Time) []Event {
+	diff = c.cfg.Edges.filter(diff)
+
+	var events []Event
+	for _, name := range diff.Pressed {
+		events = append(events, Event{Type: EventPress, Button: name, State: state, Time: now, Player: c.player, Serial: c.serial})
+	}
+	for _, name := range diff.Released {
+		events = append(events, Event{Type: EventRelease, Button: name, State: state, Time: now, Player: c.player, Serial: c.serial})
+	}
+	for _, name := range diff.Tapped {
+		events = append(events, Event{Type: EventTap, Button: name, State: state, Time: now, Player: c.player, Serial: c.serial})
+	}
+	for _, name := range diff.LongPressed {
+		events = append(events, Event{Type: EventLongPress, Button: name, State: state, Time: now, Player: c.player, Serial: c.serial})
+	}
+
+	if c.cfg.AxisEvents && state.LastState != nil {
+		last := state.LastState
+		axis := func(group string, x, y, lastX, lastY float32) {
+			events = append(events, Event{
+				Type: EventAxis, State: state, Time: now, Player: c.player, Serial: c.serial,
+				Axis: group, X: x, Y: y,
+				Delta: float32(math.Hypot(float64(x-lastX), float64(y-lastY))),
+			})
+		}
+		if diff.LeftStick {
+			axis("left", state.LEFTX, state.LEFTY, last.LEFTX, last.LEFTY)
+		}
+		if diff.RightStick {
+			axis("right", state.RIGHTX, state.RIGHTY, last.RIGHTX, last.RIGHTY)
+		}
+		if diff.Triggers {
+			axis("triggers", state.LT, state.RT, last.LT, last.RT)
+		}
+	}
+	return events
+}
+
+func isDisconnect(err error) bool {
+	return errors.Is(err, ErrDisconnected) || errors.Is(err, gousb.ErrorNoDevice) || errors.Is(err, gousb.TransferNoDevice)
+}
+
+// Poll does one read and returns the merged state with the button events
+// since the previous read, after turbo and edge filtering.
+func (c *Controller) Poll() (*ControllerState, []Event, error) {
+	state, diff, err := c.poll(c.ctx)
+	if err != nil {
+		return nil, nil, err
+	}
+	return state, c.events(state, diff, state.Time), nil
+}
+
+func (c *Controller) poll(ctx context.Context) (*ControllerState, StateDiff, error) {
+	state, diff, err := c.readStateDiff(ctx)
+	if err != nil {
+		return nil, StateDiff{}, err
+	}
+	if c.hold != nil {
+		c.hold.apply(&diff, state.Time)
+	}
+	if c.turbo != nil {
+		c.turbo.apply(state, &diff, time.Now())
+	}
+	return state, diff, nil
+}
+
+func (c *Controller) Run(ctx context.Context, fn func(*ControllerState, StateDiff) error) error {
+	return c.run(ctx, c.cfg.Reconnect, fn)
+}
+
+// Listen is the simplest way to consume input: it initializes the
+// controller, then calls onChange with every state that differs from the
+// previous one, reconnecting whenever the controller is unplugged, until
+// onChange returns an error. Listen returns that error.
+func (c *Controller) Listen(onChange func(*ControllerState) error) error {
+	if err := c.Initialize(); err != nil && !errors.Is(err, ErrReadOnly) {
+		return err
+	}
+	return c.run(c.ctx, true, func(state *ControllerState, diff StateDiff) error {
+		if !changed(state) {
+			return nil
+		}
+		return onChange(state)
+	})
+}
+
+// changed is stricter than StateDiff, whose analog flags ignore movement
+// below analogThreshold.
+func changed(state *ControllerState) bool {
+	last := state.LastState
+	if last == nil || state.ButtonMask() != last.ButtonMask() {
+		return true
+	}
+	for _, name := range AxisNames {
+		if *state.axis(name) != *last.axis(name) {
+			return true
+		}
+	}
+	return false
+}
+
+const (
+	readRetryMaxBackoff = 50 * time.Millisecond
+
+	// With reconnecting on, readRetryLimit consecutive failed reads are
+	// handled like a disconnect, so an endpoint that keeps failing is
+	// reopened rather than retried forever. Without it, reads are retried
+	// until one succeeds.
+	readRetryLimit = 20
+)
+
+// readRetryBackoff retries the first failure straight away, since a single
+// stalled or garbled transfer is usually fine on the next read, then backs
+// off from 1ms up to readRetryMaxBackoff.
+func readRetryBackoff(failures int) time.Duration {
+	if failures <= 1 {
+		return 0
+	}
+	// Capping the shift keeps a long run of failures from overflowing.
+	return min(time.Millisecond<<min(failures-2, 16), readRetryMaxBackoff)
+}
+
+func (c *Controller) run(ctx context.Context, reconnect bool, fn func(*ControllerState, StateDiff) error) error {
+	interval := c.PollInterval()
+	failures := 0
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		readCtx, resync, err := c.pause.wait(ctx)
+		if err != nil {
+			return err
+		}
+		if resync {
+			c.last = nil
+			c.cfg.Timer.restart()
+		}
+
+		turboCtx, cancelTurbo := c.turboDeadline(readCtx)
+		state, diff, err := c.poll(turboCtx)
+		// done cancels readCtx, so tell a pause from a failed read first.
+		paused := readCtx.Err() != nil && ctx.Err() == nil
+		turboDue := err != nil && !paused && errors.Is(turboCtx.Err(), context.DeadlineExceeded)
+		cancelTurbo()
+		c.pause.done()
+		if turboDue {
+			state, diff = c.turboTick(time.Now())
+			err = nil
+		}
+		if err != nil {
+			if ctx.Err() != nil {
+				return ctx.Err()
+			}
+			if paused {
+				continue
+			}
+			if errors.Is(err, ErrClosed) {
+				return err
+			}
+			failures++
+			if isDisconnect(err) || reconnect && failures >= readRetryLimit {
+				if !reconnect {
+					return err
+				}
+				if isDisconnect(err) {
+					log.Printf("Controller disconnected, reconnecting: %v", err)
+				} else {
+					log.Printf("%d reads failed in a row, reconnecting: %v", failures, err)
+				}
+				failures = 0
+				if err := c.reconnect(ctx); err != nil {
+					return err
+				}
+				continue
+			}
+			log.Printf("Read error, retrying: %v", err)
+			select {
+			case <-time.After(readRetryBackoff(failures)):
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+			continue
+		}
+		failures = 0
+
+		err = fn(state, diff)
+		c.recordLatency(state.Time)
+		if err != nil {
+			return err
+		}
+		if !c.cfg.Blocking {
+			time.Sleep(interval)
+		}
+	}
+}
+
+// Overflow decides what a full event channel does. OverflowBlock never loses
+// an event but stalls the poll loop, so reports queue up in the controller
+// and input lags behind; OverflowDropOldest keeps reading at full rate and
+// discards the oldest queued event instead, counted in Stats.DroppedEvents.
+type Overflow int
+
+const (
+	OverflowDropOldest Overflow = iota
+	OverflowBlock
+)
+
+const defaultEventBuffer = 64
+
+func sendEvent[T any](ctx context.Context, ch chan T, ev T, policy Overflow, dropped func()) error {
+	if policy == OverflowBlock || cap(ch) == 0 {
+		select {
+		case ch <- ev:
+			return nil
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	for {
+		select {
+		case ch <- ev:
+			return nil
+		default:
+		}
+		select {
+		case <-ch:
+			dropped()
+		default:
+		}
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Controller) recordDroppedEvent() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.droppedEvents++
+}
+
+// Events delivers button events on a channel buffered per Config.EventBuffer
+// and Config.EventOverflow.
+func (c *Controller) Events(ctx context.Context) <-chan Event {
+	ch := make(chan Event, c.cfg.EventBuffer)
+	send := func(ev Event) error {
+		return sendEvent(ctx, ch, ev, c.cfg.EventOverflow, c.recordDroppedEvent)
+	}
+
+	emit := func(ev Event) {
+		if c.cfg.Edges.allows(ev.Type) {
+			send(ev)
+		}
+	}
+
+	go func() {
+		defer close(ch)
+		defer c.macros.Wait()
+		err := c.Run(ctx, func(state *ControllerState, diff StateDiff) error {
+			for _, ev := range c.events(state, diff, state.Time) {
+				if err := send(ev); err != nil {
+					return err
+				}
+			}
+			c.playMacros(ctx, diff, emit)
+			return nil
+		})
+		if err == nil || ctx.Err() != nil {
+			return
+		}
+		log.Printf("Player %d event stream stopped: %v", c.player, err)
+		if c.Health() == HealthDisconnected {
+			send(Event{Type: EventDisconnect, Time: time.Now(), Player: c.player, Serial: c.serial})
+		}
+	}()
+
+	return ch
+}
+
+// Multiplex merges the event streams of several controllers, tagged with
+// their player index. A controller that disconnects sends a final
+// EventDisconnect and drops out while the others keep streaming.
+func Multiplex(ctx context.Context, controllers ...*Controller) <-chan Event {
+	out := make(chan Event)
+
+	var wg sync.WaitGroup
+	for _, c := range controllers {
+		wg.Add(1)
+		go func(c *Controller) {
+			defer wg.Done()
+			for ev := range c.Events(ctx) {
+				select {
+				case out <- ev:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(c)
+	}
+
+	go func() {
+		wg.Wait()
+		close(out)
+	}()
+
+	return out
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"testing"
+	"time"
+)
+
+func TestReadRetryBackoff(t *testing.T) {
+	ms := time.Millisecond
+	want := map[int]time.Duration{
+		0: 0, 1: 0, 2: ms, 3: 2 * ms, 4: 4 * ms, 5: 8 * ms, 6: 16 * ms, 7: 32 * ms,
+		8: readRetryMaxBackoff, 20: readRetryMaxBackoff, 1000: readRetryMaxBackoff,
+	}
+	for failures, w := range want {
+		if got := readRetryBackoff(failures); got != w {
+			t.Errorf("readRetryBackoff(%d) = %v, want %v", failures, got, w)
+		}
+	}
+}
+
+func readErrors(n int) []error {
+	errs := make([]error, n)
+	for i := range errs {
+		errs[i] = errors.New("transfer stalled")
+	}
+	return errs
+}
+
+func TestRunRetriesPastLimitWithoutReconnect(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&bytes.Buffer{})
+
+	ft := &fakeTransport{}
+	ft.queue(pressReport())
+	ft.queueErr(readErrors(readRetryLimit + 1)...)
+	ft.queue(pressReport("A"))
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithBlocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	c.reopen = func() error {
+		t.Error("reopened without WithReconnect")
+		return nil
+	}
+	states := 0
+	err = c.Run(context.Background(), func(state *ControllerState, diff StateDiff) error {
+		if states++; states == 2 {
+			if !state.A {
+				t.Error("report after the failures not delivered")
+			}
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Run gave up after %d failed reads: %v", readRetryLimit+1, err)
+	}
+}
+
+func TestRunReconnectsAtLimit(t *testing.T) {
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&bytes.Buffer{})
+
+	ft := &fakeTransport{}
+	ft.queue(pressReport())
+	ft.queueErr(readErrors(readRetryLimit)...)
+	after := &fakeTransport{}
+	after.queue(pressReport())
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithBlocking(true), WithReconnect(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	reopened := 0
+	c.reopen = func() error {
+		reopened++
+		c.attach(after)
+		return nil
+	}
+	states := 0
+	err = c.Run(context.Background(), func(*ControllerState, StateDiff) error {
+		if states++; states == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatal(err)
+	}
+	if reopened != 1 {
+		t.Fatalf("reopened %d times after %d failed reads, want once", reopened, readRetryLimit)
+	}
+}
+
+func TestListenReadOnly(t *testing.T) {
+	for _, ft := range []*fakeTransport{{noOut: true}, {}} {
+		ft.queue(pressReport("A"))
+		c, err := NewFromTransport(ft, WithReadOnly(!ft.noOut))
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = c.Listen(func(*ControllerState) error { return errStop })
+		c.Close()
+		if !errors.Is(err, errStop) {
+			t.Errorf("Listen on a read-only controller (no output %v): %v", ft.noOut, err)
+		}
+		if n := len(ft.written()); n != 0 {
+			t.Errorf("%d writes to a read-only controller (no output %v)", n, ft.noOut)
+		}
+	}
+}
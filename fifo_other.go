@@ -0,0 +1,19 @@
+//go:build !unix
+
+package main
+
+import "fmt"
+
+type fifoWriter struct{}
+
+func newFifoWriter(path string) (*fifoWriter, error) {
+	return nil, fmt.Errorf("fifo output is not supported on this platform")
+}
+
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	return 0, fmt.Errorf("fifo output is not supported on this platform")
+}
+
+func (w *fifoWriter) Close() error {
+	return nil
+}
@@ -0,0 +1,68 @@
+//go:build unix
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"syscall"
+)
+
+// fifoWriter writes to a named pipe without ever blocking the poll loop.
+// Output is dropped while no reader is attached or the reader falls behind,
+// and the pipe is reopened after the reader goes away.
+type fifoWriter struct {
+	path string
+	fd   int
+}
+
+func newFifoWriter(path string) (*fifoWriter, error) {
+	fi, err := os.Stat(path)
+	switch {
+	case errors.Is(err, os.ErrNotExist):
+		if err := syscall.Mkfifo(path, 0o644); err != nil {
+			return nil, fmt.Errorf("creating fifo %s failed: %v", path, err)
+		}
+	case err != nil:
+		return nil, err
+	case fi.Mode()&os.ModeNamedPipe == 0:
+		return nil, fmt.Errorf("%s exists and is not a fifo", path)
+	}
+	return &fifoWriter{path: path, fd: -1}, nil
+}
+
+func (w *fifoWriter) Write(p []byte) (int, error) {
+	if w.fd < 0 {
+		fd, err := syscall.Open(w.path, syscall.O_WRONLY|syscall.O_NONBLOCK|syscall.O_CLOEXEC, 0)
+		if err == syscall.ENXIO {
+			return len(p), nil
+		}
+		if err != nil {
+			return 0, fmt.Errorf("opening fifo %s failed: %v", w.path, err)
+		}
+		log.Printf("Reader attached to %s", w.path)
+		w.fd = fd
+	}
+
+	_, err := syscall.Write(w.fd, p)
+	switch err {
+	case nil, syscall.EAGAIN:
+		return len(p), nil
+	case syscall.EPIPE:
+		log.Printf("Reader detached from %s", w.path)
+		w.Close()
+		return len(p), nil
+	}
+	return 0, err
+}
+
+func (w *fifoWriter) Close() error {
+	if w.fd < 0 {
+		return nil
+	}
+	err := syscall.Close(w.fd)
+	w.fd = -1
+	return err
+}
@@ -0,0 +1,16 @@
+package main
+
+// OnGuide calls fn each time the guide button is pressed or released. It
+// follows the decoded guide report rather than the processed state, so it
+// fires whatever else is held and regardless of Remap. Repeats of an
+// unacknowledged guide report do not call it again. fn runs on the reading
+// goroutine and must not block; nil removes it.
+func (c *Controller) OnGuide(fn func(pressed bool)) {
+	c.onGuide = fn
+}
+
+func (c *Controller) notifyGuide(was bool) {
+	if c.onGuide != nil && c.raw.GUIDE != was {
+		c.onGuide(c.raw.GUIDE)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"time"
+)
+
+type HealthStatus int
+
+const (
+	HealthOK HealthStatus = iota
+	HealthIdle
+	HealthDisconnected
+)
+
+const healthIdleAfter = 5 * time.Second
+
+func (h HealthStatus) String() string {
+	switch h {
+	case HealthOK:
+		return "ok"
+	case HealthIdle:
+		return "idle"
+	case HealthDisconnected:
+		return "disconnected"
+	}
+	return "unknown"
+}
+
+func (c *Controller) recordRead(err error, now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	switch {
+	case err == nil:
+		c.lastRead = now
+		c.disconnected = false
+		c.stats.reads++
+	case isDisconnect(err):
+		c.disconnected = true
+		c.stats.errors++
+	default:
+		c.stats.errors++
+	}
+}
+
+// Health reports Disconnected once a read fails with a no-device error and
+// Idle when the controller has sent nothing for healthIdleAfter; a sleeping
+// pad simply stops sending reports, while an unplugged one fails reads.
+func (c *Controller) Health() HealthStatus {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.disconnected {
+		return HealthDisconnected
+	}
+	if time.Since(c.lastRead) > healthIdleAfter {
+		return HealthIdle
+	}
+	return HealthOK
+}
@@ -0,0 +1,57 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// defaultReleaseRatio places an unset release threshold at 80% of the press
+// threshold.
+const defaultReleaseRatio = 0.8
+
+// hysteresis derives a digital input from an analog value: it turns on once
+// the value reaches on and only turns off again below off, so a value
+// hovering at either threshold does not chatter. With hold set, the value
+// must also stay at or above on for that long before turning on, which
+// filters short spikes from analog noise.
+type hysteresis struct {
+	on, off float32
+	hold    time.Duration
+
+	active bool
+	since  time.Time
+}
+
+func newHysteresis(name string, on, off float32) (hysteresis, error) {
+	if off == 0 {
+		off = on * defaultReleaseRatio
+	}
+	if on <= 0 || on >= 1 {
+		return hysteresis{}, fmt.Errorf("%s threshold must be in (0, 1), got %v", name, on)
+	}
+	if off <= 0 || off > on {
+		return hysteresis{}, fmt.Errorf("%s release threshold must be in (0, %v], got %v", name, on, off)
+	}
+	return hysteresis{on: on, off: off}, nil
+}
+
+func (h *hysteresis) update(v float32, now time.Time) bool {
+	switch {
+	case h.active:
+		h.active = v >= h.off
+	case v < h.on:
+		h.since = time.Time{}
+	case h.hold <= 0:
+		h.active = true
+	case h.since.IsZero():
+		h.since = now
+	default:
+		h.active = now.Sub(h.since) >= h.hold
+	}
+	return h.active
+}
+
+func (h *hysteresis) reset() {
+	h.active = false
+	h.since = time.Time{}
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestHysteresisHoldTime(t *testing.T) {
+	h, err := newHysteresis("trigger", 0.5, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h.hold = 50 * time.Millisecond
+	t0 := time.Unix(100, 0)
+
+	steps := []struct {
+		v    float32
+		at   time.Duration
+		want bool
+	}{
+		{0.7, 0, false},
+		{0.7, 30 * time.Millisecond, false},
+		{0.2, 40 * time.Millisecond, false}, // a dip restarts the hold
+		{0.7, 60 * time.Millisecond, false},
+		{0.7, 100 * time.Millisecond, false},
+		{0.7, 110 * time.Millisecond, true},
+		{0.45, 120 * time.Millisecond, true},
+		{0.3, 130 * time.Millisecond, false},
+	}
+	for _, s := range steps {
+		if got := h.update(s.v, t0.Add(s.at)); got != s.want {
+			t.Errorf("%v at %v: pressed %v, want %v", s.v, s.at, got, s.want)
+		}
+	}
+}
+
+func TestTriggerHoldTimeDelaysPress(t *testing.T) {
+	const hold = 50 * time.Millisecond
+	ft := &fakeTransport{}
+	ft.queue(triggerReport(0), triggerReport(700))
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithTriggerHoldTime(hold))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 2; i++ {
+		state, diff, err := c.ReadStateDiff()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.LTPressed || len(diff.Pressed) != 0 {
+			t.Fatalf("report %d: LT pressed before the hold time: %+v", i, diff)
+		}
+	}
+
+	time.Sleep(hold)
+	ft.queue(triggerReport(700))
+	state, diff, err := c.ReadStateDiff()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !state.LTPressed || len(diff.Pressed) != 1 || diff.Pressed[0] != "LT" {
+		t.Fatalf("after the hold time: LTPressed %v, pressed %v", state.LTPressed, diff.Pressed)
+	}
+}
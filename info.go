@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/google/gousb"
+)
+
+type AudioEndpoint struct {
+	Address       gousb.EndpointAddress
+	Direction     gousb.EndpointDirection
+	TransferType  gousb.TransferType
+	MaxPacketSize int
+}
+
+type AudioInterface struct {
+	Config    int
+	Number    int
+	Alternate int
+	Class     gousb.Class
+	Endpoints []AudioEndpoint
+}
+
+type Connection int
+
+const (
+	ConnectionUnknown Connection = iota
+	ConnectionWired
+	ConnectionWireless
+)
+
+func (c Connection) String() string {
+	switch c {
+	case ConnectionWired:
+		return "wired"
+	case ConnectionWireless:
+		return "wireless"
+	}
+	return "unknown"
+}
+
+type ControllerInfo struct {
+	Bus        int
+	Address    int
+	VendorID   gousb.ID
+	ProductID  gousb.ID
+	Transport  TransportKind
+	Connection Connection
+	Audio      []AudioInterface
+}
+
+func (i ControllerInfo) HasAudio() bool {
+	return len(i.Audio) > 0
+}
+
+func containsProduct(products []gousb.ID, pid gousb.ID) bool {
+	for _, p := range products {
+		if p == pid {
+			return true
+		}
+	}
+	return false
+}
+
+func isSupportedProduct(pid gousb.ID) bool {
+	return containsProduct(supportedProducts, pid)
+}
+
+// Only controllers plugged in by cable are opened over USB. One paired to
+// the Xbox Wireless Adapter sits behind the adapter's own protocol, which
+// this package does not speak.
+func usbConnection(pid gousb.ID) Connection {
+	if isSupportedProduct(pid) {
+		return ConnectionWired
+	}
+	return ConnectionUnknown
+}
+
+func newControllerInfo(desc *gousb.DeviceDesc) ControllerInfo {
+	return ControllerInfo{
+		Bus:        desc.Bus,
+		Address:    desc.Address,
+		VendorID:   desc.Vendor,
+		ProductID:  desc.Product,
+		Transport:  TransportUSB,
+		Connection: usbConnection(desc.Product),
+		Audio:      audioInterfaces(desc),
+	}
+}
+
+// The GIP headset/chat interface is vendor-specific rather than USB audio
+// class, so any alt setting with isochronous endpoints is treated as audio.
+func audioInterfaces(desc *gousb.DeviceDesc) []AudioInterface {
+	var audio []AudioInterface
+
+	for _, cfg := range desc.Configs {
+		for _, intf := range cfg.Interfaces {
+			for _, alt := range intf.AltSettings {
+				var endpoints []AudioEndpoint
+				for _, ep := range alt.Endpoints {
+					if alt.Class != gousb.ClassAudio && ep.TransferType != gousb.TransferTypeIsochronous {
+						continue
+					}
+					endpoints = append(endpoints, AudioEndpoint{
+						Address:       ep.Address,
+						Direction:     ep.Direction,
+						TransferType:  ep.TransferType,
+						MaxPacketSize: ep.MaxPacketSize,
+					})
+				}
+
+				if len(endpoints) == 0 {
+					continue
+				}
+
+				audio = append(audio, AudioInterface{
+					Config:    cfg.Number,
+					Number:    alt.Number,
+					Alternate: alt.Alternate,
+					Class:     alt.Class,
+					Endpoints: endpoints,
+				})
+			}
+		}
+	}
+
+	return audio
+}
+
+func ListControllers() ([]ControllerInfo, error) {
+	ctx := gousb.NewContext()
+	defer ctx.Close()
+
+	var infos []ControllerInfo
+	_, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if desc.Vendor == VendorMicrosoft && usbConnection(desc.Product) != ConnectionUnknown {
+			infos = append(infos, newControllerInfo(desc))
+		}
+		return false
+	})
+	if err != nil {
+		return infos, fmt.Errorf("enumerating USB devices failed: %v", err)
+	}
+
+	bt, err := findBluetoothControllers(bluetoothProducts)
+	if err != nil {
+		return infos, fmt.Errorf("enumerating Bluetooth devices failed: %v", err)
+	}
+	for _, dev := range bt {
+		infos = append(infos, ControllerInfo{
+			VendorID:   VendorMicrosoft,
+			ProductID:  dev.product,
+			Transport:  TransportBluetooth,
+			Connection: ConnectionWireless,
+		})
+	}
+
+	return infos, nil
+}
+
+// logEndpoints prints every descriptor of each Microsoft USB device so users
+// can find the interface and endpoint numbers for -interface/-in-ep/-out-ep.
+func logEndpoints() error {
+	ctx := gousb.NewContext()
+	defer ctx.Close()
+
+	found := 0
+	_, err := ctx.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if desc.Vendor != VendorMicrosoft {
+			return false
+		}
+		found++
+
+		log.Printf("Device %s:%s on bus %d address %d (%s)", desc.Vendor, desc.Product, desc.Bus, desc.Address, usbConnection(desc.Product))
+		for _, cfg := range desc.Configs {
+			log.Printf("  Config %d", cfg.Number)
+			for _, intf := range cfg.Interfaces {
+				for _, alt := range intf.AltSettings {
+					log.Printf("    Interface %d alt %d: class %s, subclass %s, protocol %s", alt.Number, alt.Alternate, alt.Class, alt.SubClass, alt.Protocol)
+
+					addrs := make([]int, 0, len(alt.Endpoints))
+					for addr := range alt.Endpoints {
+						addrs = append(addrs, int(addr))
+					}
+					sort.Ints(addrs)
+					for _, addr := range addrs {
+						ep := alt.Endpoints[gousb.EndpointAddress(addr)]
+						log.Printf("      Endpoint %d %s (%s): %s, max packet %d bytes", ep.Number, ep.Direction, ep.Address, ep.TransferType, ep.MaxPacketSize)
+					}
+				}
+			}
+		}
+		return false
+	})
+	if err != nil {
+		return fmt.Errorf("enumerating USB devices failed: %v", err)
+	}
+	if found == 0 {
+		log.Println("No Microsoft USB devices found")
+	}
+	return nil
+}
+
+// Info describes the device as it was when last opened, so it stays
+// available while reconnecting.
+func (c *Controller) Info() ControllerInfo {
+	return c.info
+}
+
+func (c *Controller) Connection() Connection {
+	return c.Info().Connection
+}
+
+// Wireless reports a Bluetooth connection; USB controllers are always wired.
+func (c *Controller) Wireless() bool {
+	return c.Connection() == ConnectionWireless
+}
+
+func (c *Controller) strings() (DeviceStrings, error) {
+	if c.transport == nil {
+		return DeviceStrings{}, ErrDisconnected
+	}
+	return c.transport.Strings()
+}
+
+func (c *Controller) SerialNumber() (string, error) {
+	s, err := c.strings()
+	return s.Serial, err
+}
+
+func (c *Controller) Manufacturer() (string, error) {
+	s, err := c.strings()
+	return s.Manufacturer, err
+}
+
+func (c *Controller) Product() (string, error) {
+	s, err := c.strings()
+	return s.Product, err
+}
+
+func logDeviceStrings(c *Controller) {
+	manufacturer, err := c.Manufacturer()
+	if err != nil {
+		log.Printf("Could not read manufacturer: %v", err)
+	}
+	product, err := c.Product()
+	if err != nil {
+		log.Printf("Could not read product: %v", err)
+	}
+	serial, err := c.SerialNumber()
+	if err != nil {
+		log.Printf("Could not read serial number: %v", err)
+	}
+	log.Printf("Device: %s %s (serial %s)", manufacturer, product, serial)
+}
+
+func logControllerInfo(info ControllerInfo) {
+	if info.Transport == TransportBluetooth {
+		log.Printf("Xbox controller %s:%s over Bluetooth (%s)", info.VendorID, info.ProductID, info.Connection)
+	} else {
+		log.Printf("Xbox controller %s:%s on bus %d address %d (%s)", info.VendorID, info.ProductID, info.Bus, info.Address, info.Connection)
+	}
+
+	if !info.HasAudio() {
+		log.Println("  No audio interfaces")
+		return
+	}
+
+	for _, a := range info.Audio {
+		log.Printf("  Audio interface %d alt %d (config %d, class %s)", a.Number, a.Alternate, a.Config, a.Class)
+		for _, ep := range a.Endpoints {
+			log.Printf("    Endpoint %s %s %s, max packet %d bytes", ep.Address, ep.Direction, ep.TransferType, ep.MaxPacketSize)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"log"
+	"time"
+)
+
+// WithLatencyBudget measures, for every Run or Listen callback, the time
+// from the host receiving the report to the callback returning, and counts
+// each one over budget in Stats.OverBudget. The Events channels are not
+// covered since the handler runs on the consumer's side of the channel.
+func WithLatencyBudget(budget time.Duration) Option {
+	return func(c *Controller) {
+		c.cfg.LatencyBudget = budget
+	}
+}
+
+const latencyLogInterval = time.Second
+
+func (c *Controller) recordLatency(received time.Time) {
+	if c.cfg.LatencyBudget <= 0 || received.IsZero() {
+		return
+	}
+	now := time.Now()
+	d := now.Sub(received)
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.maxLatency = max(c.stats.maxLatency, d)
+	if d <= c.cfg.LatencyBudget {
+		return
+	}
+	c.stats.overBudget++
+
+	// A slow handler is usually slow on every report, so log at most once
+	// a second rather than flooding the output.
+	if now.Sub(c.stats.latencyLogged) >= latencyLogInterval {
+		log.Printf("Handler finished %v after the report arrived, over the %v budget (%d times so far)", d.Round(time.Microsecond), c.cfg.LatencyBudget, c.stats.overBudget)
+		c.stats.latencyLogged = now
+	}
+}
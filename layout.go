@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+type ButtonBit struct {
+	Byte int
+	Mask byte
+}
+
+type ButtonLayout map[string]ButtonBit
+
+var StandardLayout = ButtonLayout{
+	"A":     {3, 0x10},
+	"B":     {3, 0x40},
+	"X":     {3, 0x20},
+	"Y":     {3, 0x80},
+	"MENU":  {3, 0x04},
+	"VIEW":  {3, 0x08},
+	"SHARE": {3, 0x01},
+	"UP":    {4, 0x01},
+	"DOWN":  {4, 0x02},
+	"LEFT":  {4, 0x04},
+	"RIGHT": {4, 0x08},
+	"LB":    {4, 0x10},
+	"RB":    {4, 0x20},
+	"LS":    {4, 0x40},
+	"RS":    {4, 0x80},
+}
+
+func (l ButtonLayout) Validate() error {
+	var s ControllerState
+	for name, bit := range l {
+		if name == "GUIDE" || s.button(name) == nil {
+			return fmt.Errorf("unknown button %q in layout; valid buttons are %s except GUIDE, which has its own report", name, validButtons())
+		}
+		if bit.Byte < 1 || bit.Byte >= 64 {
+			return fmt.Errorf("button %s: byte %d is outside the input report", name, bit.Byte)
+		}
+		if bit.Mask == 0 {
+			return fmt.Errorf("button %s: mask must not be zero", name)
+		}
+	}
+	return nil
+}
+
+func (l ButtonLayout) apply(s *ControllerState, buf []byte) {
+	for name, bit := range l {
+		if b := s.button(name); b != nil && bit.Byte < len(buf) {
+			*b = buf[bit.Byte]&bit.Mask != 0
+		}
+	}
+}
+
+func (l ButtonLayout) String() string {
+	names := make([]string, 0, len(l))
+	for name := range l {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	parts := make([]string, len(names))
+	for i, name := range names {
+		parts[i] = fmt.Sprintf("%s=%d:0x%02x", name, l[name].Byte, l[name].Mask)
+	}
+	return strings.Join(parts, ",")
+}
+
+// ParseButtonLayout reads "NAME=byte:mask,..." overrides on top of the
+// standard layout, e.g. "A=3:0x20,X=3:0x10" for a clone with A/X swapped.
+func ParseButtonLayout(spec string) (ButtonLayout, error) {
+	layout := make(ButtonLayout, len(StandardLayout))
+	for name, bit := range StandardLayout {
+		layout[name] = bit
+	}
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name, pos, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid layout entry %q", entry)
+		}
+		byteStr, maskStr, ok := strings.Cut(pos, ":")
+		if !ok {
+			return nil, fmt.Errorf("invalid layout entry %q, expected NAME=byte:mask", entry)
+		}
+
+		b, err := strconv.Atoi(byteStr)
+		if err != nil {
+			return nil, fmt.Errorf("invalid byte in layout entry %q: %v", entry, err)
+		}
+		mask, err := strconv.ParseUint(maskStr, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid mask in layout entry %q: %v", entry, err)
+		}
+
+		layout[strings.ToUpper(strings.TrimSpace(name))] = ButtonBit{Byte: b, Mask: byte(mask)}
+	}
+
+	if err := layout.Validate(); err != nil {
+		return nil, err
+	}
+	return layout, nil
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// longPress classifies each release of its buttons as a tap or a long press
+// by how long the button was held. The reports only carry the button bit, so
+// a Series controller's screenshot/clip distinction on SHARE is not visible
+// and has to be timed here. Classification happens on release because
+// controllers stop reporting while nothing changes.
+type longPress struct {
+	after time.Duration
+	since map[string]time.Time
+}
+
+func newLongPress(after time.Duration, buttons []string) (*longPress, error) {
+	if after <= 0 {
+		return nil, fmt.Errorf("long press duration must be positive, got %v", after)
+	}
+
+	l := &longPress{after: after, since: make(map[string]time.Time)}
+
+	var s ControllerState
+	for _, name := range buttons {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if s.button(name) == nil {
+			return nil, fmt.Errorf("unknown long press button %q", name)
+		}
+		l.since[name] = time.Time{}
+	}
+	return l, nil
+}
+
+func (l *longPress) apply(diff *StateDiff, now time.Time) {
+	for _, name := range diff.Pressed {
+		if _, ok := l.since[name]; ok {
+			l.since[name] = now
+		}
+	}
+	for _, name := range diff.Released {
+		start, ok := l.since[name]
+		if !ok || start.IsZero() {
+			continue
+		}
+		l.since[name] = time.Time{}
+		if now.Sub(start) >= l.after {
+			diff.LongPressed = append(diff.LongPressed, name)
+		} else {
+			diff.Tapped = append(diff.Tapped, name)
+		}
+	}
+}
+
+func (l *longPress) reset() {
+	for name := range l.since {
+		l.since[name] = time.Time{}
+	}
+}
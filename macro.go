@@ -0,0 +1,131 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type MacroAction int
+
+const (
+	MacroTap MacroAction = iota
+	MacroPress
+	MacroRelease
+)
+
+type MacroStep struct {
+	Action MacroAction
+	Button string
+	Delay  time.Duration
+}
+
+type Macro []MacroStep
+
+// ParseMacros reads "TRIGGER=STEP,STEP,...;TRIGGER=..." where a step is a
+// button name (tap), +NAME (press), -NAME (release) or a duration to wait
+// before the next step, e.g. "RB=+A,50ms,-A,100ms,B". LT and RT can trigger
+// a macro, firing when pulled past the trigger threshold.
+func ParseMacros(spec string) (map[string]Macro, error) {
+	macros := make(map[string]Macro)
+	var s ControllerState
+
+	for _, def := range strings.Split(spec, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+
+		trigger, steps, ok := strings.Cut(def, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid macro %q, expected TRIGGER=STEP,...", def)
+		}
+		trigger = strings.ToUpper(strings.TrimSpace(trigger))
+		if s.button(trigger) == nil && !isTrigger(trigger) {
+			return nil, fmt.Errorf("unknown macro trigger %q", trigger)
+		}
+
+		var macro Macro
+		var delay time.Duration
+		for _, tok := range strings.Split(steps, ",") {
+			tok = strings.TrimSpace(tok)
+			if tok == "" {
+				continue
+			}
+
+			if d, err := time.ParseDuration(tok); err == nil {
+				delay += d
+				continue
+			}
+
+			step := MacroStep{Action: MacroTap, Delay: delay}
+			switch tok[0] {
+			case '+':
+				step.Action = MacroPress
+				tok = tok[1:]
+			case '-':
+				step.Action = MacroRelease
+				tok = tok[1:]
+			}
+			step.Button = strings.ToUpper(tok)
+			if s.button(step.Button) == nil {
+				return nil, fmt.Errorf("unknown button %q in macro for %s", tok, trigger)
+			}
+
+			macro = append(macro, step)
+			delay = 0
+		}
+
+		if len(macro) == 0 {
+			return nil, fmt.Errorf("macro for %s has no steps", trigger)
+		}
+		macros[trigger] = macro
+	}
+
+	return macros, nil
+}
+
+func (c *Controller) playMacros(ctx context.Context, diff StateDiff, emit func(Event)) {
+	for _, name := range diff.Pressed {
+		macro, ok := c.cfg.Macros[name]
+		if !ok {
+			continue
+		}
+
+		c.macros.Add(1)
+		go func(trigger string, macro Macro) {
+			defer c.macros.Done()
+			c.playMacro(ctx, trigger, macro, emit)
+		}(name, macro)
+	}
+}
+
+func (c *Controller) playMacro(ctx context.Context, trigger string, macro Macro, emit func(Event)) {
+	for _, step := range macro {
+		if step.Delay > 0 {
+			t := time.NewTimer(step.Delay)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+
+		ev := Event{Button: step.Button, Time: time.Now(), Player: c.player, Serial: c.serial, Macro: trigger}
+		switch step.Action {
+		case MacroPress:
+			ev.Type = EventPress
+			emit(ev)
+		case MacroRelease:
+			ev.Type = EventRelease
+			emit(ev)
+		default:
+			ev.Type = EventPress
+			emit(ev)
+			ev.Type = EventRelease
+			emit(ev)
+		}
+	}
+}
@@ -0,0 +1,645 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"syscall"
+	"time"
+)
+
+var (
+	pollingFrequency = flag.String("freq", "500", "Polling frequency in Hz, auto to match the controller's report rate, or 0 for -blocking")
+	blocking         = flag.Bool("blocking", false, "Handle each report as soon as it arrives instead of sleeping between polls")
+	readonly         = flag.Bool("readonly", false, "Only read from the controller")
+	debug            = flag.Int("debug", 0, "USB debugging control")
+	list             = flag.Bool("list", false, "List connected controllers and their audio endpoints, then exit")
+	listModels       = flag.Bool("models", false, "List the controller models and product IDs this build recognizes, then exit")
+	listEndpoints    = flag.Bool("list-endpoints", false, "Print the USB config, interface and endpoint descriptors of Microsoft devices, then exit")
+	usbInterface     = flag.Int("interface", 0, "USB interface number to claim")
+	inEndpoint       = flag.Int("in-ep", 1, "USB IN endpoint number for input reports")
+	outEndpoint      = flag.Int("out-ep", 1, "USB OUT endpoint number for commands, 0 to open read-only")
+	ignoreReports    = flag.String("ignore-reports", "", "Comma-separated report IDs to drop without decoding or logging, e.g. 0x03,0x0a")
+	initPackets      = flag.String("init", "", "Hex init packets to send instead of the default 05 20, comma-separated, e.g. \"05 20,0a 20 00 03 00 01 14\"")
+	deadzone         = flag.Float64("deadzone", 0.1, "Stick deadzone, 0 disables it and passes raw values through")
+	triggerDeadzone  = flag.Float64("trigger-deadzone", 0.02, "Trigger values below this read as 0, to stop a resting finger from jittering")
+	triggerThreshold = flag.Float64("trigger-threshold", 0.5, "How far LT/RT must be pulled to count as pressed, which is logged as an LT or RT button press")
+	triggerRelease   = flag.Float64("trigger-release", 0, "How far a pressed LT/RT must let go to count as released, 0 for 80% of -trigger-threshold")
+	triggerHoldTime  = flag.Duration("trigger-hold-time", 0, "How long LT/RT must stay past -trigger-threshold before the press is reported, to filter analog flicker, e.g. 30ms")
+	deadzoneDebug    = flag.Bool("deadzone-debug", false, "Log raw and deadzone-applied stick values side by side instead of button events")
+	radialDeadzone   = flag.Bool("radial-deadzone", false, "Apply the deadzone to the stick vector, rescaled so direction is preserved past the edge")
+	leftSensitivity  = flag.Float64("left-sensitivity", 1, "Linear multiplier for the left stick, clamped to the full range")
+	rightSensitivity = flag.Float64("right-sensitivity", 1, "Linear multiplier for the right stick, clamped to the full range")
+	clampStick       = flag.Bool("clamp-sticks", false, "Constrain each stick to the unit circle instead of the raw square range")
+	reportFormat     = flag.String("report-format", "auto", "Input report layout: auto, gip (USB report 0x20) or hid (report 0x01, Bluetooth and HID-mode firmware)")
+	transport        = flag.String("transport", "usb", "Controller transport: usb or bluetooth (Linux hidraw)")
+	layout           = flag.String("layout", "", "Button layout overrides for clone controllers, e.g. A=3:0x20,X=3:0x10")
+	all              = flag.Bool("all", false, "Open every connected controller and log button events per player")
+	axisEvents       = flag.Bool("axis-events", false, "With -all, also log stick and trigger movement per player")
+	turboButtons     = flag.String("turbo", "", "Comma-separated buttons that auto-fire while held, e.g. A,B")
+	turboRate        = flag.Int("turbo-rate", 10, "Turbo presses per second")
+	longPressButtons = flag.String("long-press", "", "Comma-separated buttons whose releases are logged as a tap or long press, e.g. SHARE")
+	longPressTime    = flag.Duration("long-press-time", 500*time.Millisecond, "How long a -long-press button must be held to count as a long press")
+	remap            = flag.String("remap", "", "Input remapping, e.g. A=B,B=A,RT=RIGHTY,LEFTX=-RIGHTX*1.5")
+	stickDpadWays    = flag.Int("stick-dpad", 0, "Drive the d-pad from the left stick with 4 or 8-way snapping, 0 to disable")
+	macros           = flag.String("macro", "", "Button macros, e.g. RB=+A,50ms,-A,100ms,B;LB=X,X")
+	edges            = flag.String("edges", "both", "Button edges to report: both, rising (press) or falling (release)")
+	format           = flag.String("format", "text", "Output formats, comma-separated: text, json or logfmt; text logs to stderr and can be combined with one of the others")
+	outputRate       = flag.Int("output-rate", 0, "Maximum JSON state updates per second for analog changes, 0 for unlimited")
+	rumbleTriggers   = flag.Bool("rumble-triggers", false, "Demo mode: drive the trigger rumble motors from how far LT/RT are pulled")
+	telemetryPath    = flag.String("telemetry", "", "Write the analog axes at a fixed rate, changed or not, to this file (- for stdout)")
+	telemetryRate    = flag.Int("telemetry-rate", 100, "Samples per second for -telemetry")
+	telemetryFormat  = flag.String("telemetry-format", "csv", "Format for -telemetry: csv or json")
+	pipePath         = flag.String("pipe", "", "Also write newline-delimited JSON state changes to this named pipe")
+	socketPath       = flag.String("unix", "", "Serve newline-delimited JSON state changes to any number of clients on this Unix domain socket")
+	oscAddr          = flag.String("osc", "", "Send button and axis changes as OSC messages over UDP to this host:port")
+	oscPrefix        = flag.String("osc-prefix", "/xbox", "Address prefix for -osc messages")
+	midiPort         = flag.String("midi", "", "Write buttons as MIDI notes and axes as CCs to this raw MIDI device, e.g. /dev/snd/midiC1D0")
+	midiChannel      = flag.Int("midi-channel", 1, "MIDI channel for -midi, 1-16")
+	midiMap          = flag.String("midi-map", "", "MIDI mapping replacing the default, e.g. A=60,B=62,RT=cc74")
+	logAxes          = flag.String("log-axes", "all", "Analog groups to log: comma-separated left, right, triggers, or all/none")
+	capturePath      = flag.String("capture", "", "Write every raw report and output packet with timestamps to this file")
+	replayRaw        = flag.String("replay-raw", "", "Decode and log the input reports from a -capture file instead of opening a controller")
+	idleExit         = flag.Duration("idle-exit", 0, "Exit after this long without any button or axis change, e.g. 30s; 0 waits forever")
+	idleAxes         = flag.String("idle-axes", "all", "Analog groups that count as activity for -idle-exit: comma-separated left, right, triggers, or all/none; none counts buttons only, for drifting sticks")
+	noAnalogLog      = flag.Bool("no-analog-log", false, "Only log button presses and releases, never stick or trigger movement")
+	virtualPad       = flag.Bool("uinput", false, "Mirror the controller as a virtual Linux gamepad, after remapping and deadzones")
+	virtualPadRaw    = flag.Bool("uinput-raw", false, "Mirror raw reports to a virtual Linux gamepad directly, skipping decode, remaps and deadzones")
+	statsAddr        = flag.String("stats-addr", "", "Serve plain-text read statistics at GET /stats on this address, e.g. localhost:8080")
+	diagnose         = flag.Bool("diagnose", false, "Warn with the raw report when decoded input is physically impossible, such as UP and DOWN together")
+	latencyBudget    = flag.Duration("latency-budget", 0, "Log and count reports whose handling finished later than this after they arrived, e.g. 5ms")
+	calibrationPath  = flag.String("calibration", "", "JSON file of per-controller stick calibration, loaded by serial number at startup")
+	calibrate        = flag.Bool("calibrate", false, "Measure the resting sticks for 2 seconds, save the result to -calibration and exit")
+	reconnect        = flag.Bool("reconnect", false, "Keep waiting for the controller to come back after it is unplugged")
+	once             = flag.Bool("once", false, "Print the current state once, as text or with -format json or logfmt, and exit")
+	reportCard       = flag.Bool("test", false, "Walk through every button, stick and trigger, then print a pass/fail report card and exit")
+	timing           = flag.Bool("timing", false, "Record the interval between input reports and print percentiles on exit")
+)
+
+// setPollingFrequency returns the period for hz, or 16ms for hz <= 0. A
+// Duration holds whole nanoseconds, so rates that do not divide a second
+// evenly run under 1ns per period fast. Rates above 1GHz give 0, so the read
+// loop does not sleep at all.
+func setPollingFrequency(hz int) time.Duration {
+	if hz <= 0 {
+		return 16 * time.Millisecond
+	}
+	return time.Second / time.Duration(hz)
+}
+
+func logStateChanges(current *ControllerState, diff StateDiff) {
+	for _, name := range diff.Pressed {
+		log.Printf("%s pressed", name)
+	}
+	for _, name := range diff.Released {
+		log.Printf("%s released", name)
+	}
+	for _, name := range diff.Tapped {
+		log.Printf("%s tapped", name)
+	}
+	for _, name := range diff.LongPressed {
+		log.Printf("%s long-pressed", name)
+	}
+
+	if diff.LeftStick {
+		log.Printf("Left stick: %.2f, %.2f", current.LEFTX, current.LEFTY)
+	}
+
+	if diff.RightStick {
+		log.Printf("Right stick: %.2f, %.2f", current.RIGHTX, current.RIGHTY)
+	}
+
+	if diff.Triggers {
+		log.Printf("Triggers: LT=%.2f RT=%.2f", current.LT, current.RT)
+	}
+}
+
+func logMacroEvent(ev Event) {
+	log.Printf("Macro %s: %s %s", ev.Macro, ev.Button, ev.Type)
+}
+
+// fatalCleanups run before fatalf exits, since os.Exit skips deferred calls
+// and would leave files such as the -unix socket behind.
+var fatalCleanups []func()
+
+func atFatal(fn func()) {
+	fatalCleanups = append(fatalCleanups, fn)
+}
+
+func fatalf(format string, args ...any) {
+	for i := len(fatalCleanups) - 1; i >= 0; i-- {
+		fatalCleanups[i]()
+	}
+	log.Fatalf(format, args...)
+}
+
+func runAll(ctx context.Context, opts []Option) {
+	controllers, err := OpenAll(opts...)
+	if err != nil {
+		fatalf("Failed to open controllers: %v", err)
+	}
+
+	for _, c := range controllers {
+		defer c.Close()
+		if err := c.Initialize(); err != nil && !errors.Is(err, ErrReadOnly) {
+			log.Printf("Player %d: failed to initialize: %v", c.Player(), err)
+		}
+	}
+	log.Printf("%d controllers connected", len(controllers))
+
+	for ev := range Multiplex(ctx, controllers...) {
+		switch ev.Type {
+		case EventDisconnect:
+			log.Printf("Player %d (%s) disconnected", ev.Player, ev.Serial)
+			continue
+		case EventAxis:
+			log.Printf("Player %d (%s): %s moved to %.2f, %.2f (by %.2f)", ev.Player, ev.Serial, ev.Axis, ev.X, ev.Y, ev.Delta)
+			continue
+		}
+		log.Printf("Player %d (%s): %s %s", ev.Player, ev.Serial, ev.Button, ev.Type)
+	}
+}
+
+// exitCode is the status main exits with once its deferred cleanups have
+// run; a failed -test sets it rather than calling os.Exit directly.
+var exitCode int
+
+func main() {
+	defer func() {
+		if exitCode != 0 {
+			os.Exit(exitCode)
+		}
+	}()
+	flag.Parse()
+
+	if *list {
+		infos, err := ListControllers()
+		if err != nil {
+			fatalf("Failed to list controllers: %v", err)
+		}
+		if len(infos) == 0 {
+			log.Println("No Xbox controllers found")
+		}
+		for _, info := range infos {
+			logControllerInfo(info)
+		}
+		return
+	}
+
+	if *listModels {
+		for _, m := range SupportedModels() {
+			logModelInfo(m)
+		}
+		return
+	}
+
+	if *listEndpoints {
+		if err := logEndpoints(); err != nil {
+			fatalf("Failed to list endpoints: %v", err)
+		}
+		return
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	buttonLayout, err := ParseButtonLayout(*layout)
+	if err != nil {
+		fatalf("Invalid button layout: %v", err)
+	}
+
+	if *replayRaw != "" {
+		if err := replayCapture(*replayRaw, buttonLayout, float32(*deadzone), float32(*triggerDeadzone)); err != nil {
+			fatalf("Replay failed: %v", err)
+		}
+		return
+	}
+
+	tr, err := ParseTransport(*transport)
+	if err != nil {
+		fatalf("Invalid transport: %v", err)
+	}
+
+	reportFmt, err := ParseReportFormat(*reportFormat)
+	if err != nil {
+		fatalf("Invalid report format: %v", err)
+	}
+
+	edge, err := ParseEdge(*edges)
+	if err != nil {
+		fatalf("Invalid edges: %v", err)
+	}
+
+	autoFreq := *pollingFrequency == "auto"
+	freq := DefaultConfig().PollRate
+	if !autoFreq {
+		freq, err = strconv.Atoi(*pollingFrequency)
+		if err != nil {
+			fatalf("Invalid polling frequency %q: %v", *pollingFrequency, err)
+		}
+		if freq == 0 {
+			*blocking = true
+		}
+	}
+
+	opts := []Option{
+		WithTransport(tr),
+		WithReportFormat(reportFmt),
+		WithEdges(edge),
+		WithPollRate(freq),
+		WithBlocking(*blocking),
+		WithDeadzone(float32(*deadzone)),
+		WithTriggerDeadzone(float32(*triggerDeadzone)),
+		WithTriggerThresholds(float32(*triggerThreshold), float32(*triggerRelease)),
+		WithTriggerHoldTime(*triggerHoldTime),
+		WithButtonLayout(buttonLayout),
+		WithReadOnly(*readonly),
+		WithEndpoints(*usbInterface, *inEndpoint, *outEndpoint),
+		WithReconnect(*reconnect),
+		WithLatencyBudget(*latencyBudget),
+		WithDiagnostics(*diagnose),
+		WithStickClamp(*clampStick),
+		WithRadialDeadzone(*radialDeadzone),
+		WithStickSensitivity(float32(*leftSensitivity), float32(*rightSensitivity)),
+		WithContext(ctx),
+	}
+	if *initPackets != "" {
+		packets, err := ParseInitPackets(*initPackets)
+		if err != nil {
+			fatalf("Invalid -init: %v", err)
+		}
+		opts = append(opts, WithInitPackets(packets...))
+	}
+	if *capturePath != "" {
+		f, err := os.Create(*capturePath)
+		if err != nil {
+			fatalf("Failed to create capture file: %v", err)
+		}
+		defer f.Close()
+
+		cw, err := NewCaptureWriter(f, reportFmt.resolve(tr))
+		if err != nil {
+			fatalf("Failed to start capture: %v", err)
+		}
+		opts = append(opts, WithCapture(cw))
+	}
+	var pad *VirtualGamepad
+	if *virtualPad || *virtualPadRaw {
+		pad, err = NewVirtualGamepad(buttonLayout)
+		if err != nil {
+			fatalf("Failed to create virtual gamepad: %v", err)
+		}
+		defer pad.Close()
+	}
+	if *ignoreReports != "" {
+		ids, err := ParseReportIDs(*ignoreReports)
+		if err != nil {
+			fatalf("Invalid -ignore-reports: %v", err)
+		}
+		opts = append(opts, WithIgnoreReports(ids...))
+	}
+	if *virtualPadRaw {
+		if tr != TransportUSB || reportFmt == ReportFormatHID {
+			fatalf("-uinput-raw only understands USB reports")
+		}
+		opts = append(opts, WithReportHook(func(report []byte) {
+			if err := pad.WriteReport(report); err != nil {
+				log.Printf("Virtual gamepad: %v", err)
+			}
+		}))
+	}
+	var dzView *deadzoneView
+	if *deadzoneDebug {
+		dzView = &deadzoneView{}
+	}
+	if *stickDpadWays != 0 {
+		opts = append(opts, WithStickDpad(*stickDpadWays, DefaultConfig().StickDpadThreshold))
+	}
+	if *remap != "" {
+		r, err := ParseRemap(*remap)
+		if err != nil {
+			fatalf("Invalid remap: %v", err)
+		}
+		opts = append(opts, WithRemap(r))
+	}
+	if *macros != "" {
+		m, err := ParseMacros(*macros)
+		if err != nil {
+			fatalf("Invalid macro: %v", err)
+		}
+		opts = append(opts, WithMacros(m))
+	}
+	if *turboButtons != "" {
+		opts = append(opts, WithTurbo(*turboRate, strings.Split(*turboButtons, ",")...))
+	}
+	if *longPressButtons != "" {
+		opts = append(opts, WithLongPress(*longPressTime, strings.Split(*longPressButtons, ",")...))
+	}
+
+	if *all {
+		opts = append(opts, WithAxisEvents(*axisEvents))
+		runAll(ctx, opts)
+		return
+	}
+
+	axes, err := ParseAnalogGroups(*logAxes)
+	if err != nil {
+		fatalf("Invalid -log-axes: %v", err)
+	}
+	if *noAnalogLog {
+		axes = AnalogGroups{}
+	}
+	activity, err := ParseAnalogGroups(*idleAxes)
+	if err != nil {
+		fatalf("Invalid -idle-axes: %v", err)
+	}
+
+	var fan fanout
+	stdout := ""
+	for _, f := range strings.Split(*format, ",") {
+		switch f {
+		case "text":
+			fan.add("text", sinkFunc(func(state *ControllerState, diff StateDiff) error {
+				logStateChanges(state, axes.filter(edge.filter(diff)))
+				return nil
+			}))
+		case "json", "logfmt":
+			if stdout != "" {
+				fatalf("-format %s and %s both write to stdout", stdout, f)
+			}
+			stdout = f
+			var write func(*ControllerState, StateDiff) error = newJSONOutput(os.Stdout).write
+			if f == "logfmt" {
+				write = newLogfmtOutput(os.Stdout).write
+			}
+			t := newThrottle(*outputRate, func(state *ControllerState, diff StateDiff) {
+				if err := write(state, diff); err != nil {
+					log.Printf("%s output failed: %v", f, err)
+				}
+			})
+			fan.add(f, sinkFunc(func(state *ControllerState, diff StateDiff) error {
+				t.push(state, edge.filter(diff))
+				return nil
+			}))
+		default:
+			fatalf("Unknown output format %q", f)
+		}
+	}
+
+	notify, err := setupNotifications()
+	if err != nil {
+		fatalf("Invalid notifications: %v", err)
+	}
+	if notify != nil {
+		fan.add("notification", sinkFunc(func(state *ControllerState, diff StateDiff) error {
+			notify(state, diff)
+			return nil
+		}))
+	}
+
+	if *pipePath != "" {
+		fifo, err := newFifoWriter(*pipePath)
+		if err != nil {
+			fatalf("Failed to set up pipe output: %v", err)
+		}
+		defer fifo.Close()
+
+		out := newJSONOutput(fifo)
+		fan.add("pipe", sinkFunc(func(state *ControllerState, diff StateDiff) error {
+			if diff := edge.filter(diff); !diff.Empty() {
+				return out.write(state, diff)
+			}
+			return nil
+		}))
+	}
+
+	var tel *telemetry
+	if *telemetryPath != "" {
+		w := os.Stdout
+		if *telemetryPath == "-" && stdout != "" {
+			fatalf("-telemetry - and -format %s both write to stdout", stdout)
+		}
+		if *telemetryPath != "-" {
+			f, err := os.Create(*telemetryPath)
+			if err != nil {
+				fatalf("Failed to set up telemetry: %v", err)
+			}
+			defer f.Close()
+			w = f
+		}
+		if tel, err = newTelemetry(w, *telemetryFormat, *telemetryRate); err != nil {
+			fatalf("Failed to set up telemetry: %v", err)
+		}
+	}
+
+	if *socketPath != "" {
+		sock, err := newSocketServer(*socketPath)
+		if err != nil {
+			fatalf("Failed to set up socket output: %v", err)
+		}
+		atFatal(func() { sock.Close() })
+		fan.add("socket", filterSink{sock, edge.filter})
+	}
+
+	if *oscAddr != "" {
+		osc, err := newOSCSender(*oscAddr, *oscPrefix)
+		if err != nil {
+			fatalf("Failed to set up OSC output: %v", err)
+		}
+		fan.add("OSC", filterSink{osc, edge.filter})
+	}
+
+	if *midiPort != "" {
+		m := DefaultMIDIMap()
+		if *midiMap != "" {
+			if m, err = ParseMIDIMap(*midiMap); err != nil {
+				fatalf("Invalid -midi-map: %v", err)
+			}
+		}
+		midi, err := newMIDIOutput(*midiPort, *midiChannel, m)
+		if err != nil {
+			fatalf("Failed to set up MIDI output: %v", err)
+		}
+		fan.add("MIDI", filterSink{midi, edge.filter})
+	}
+	// Deferred after the pipe so the queued changes are flushed before the
+	// fifo closes.
+	defer fan.Close()
+
+	if *timing {
+		timer := &ReportTimer{}
+		opts = append(opts, WithReportTimer(timer))
+		defer logReportTiming(timer)
+	}
+
+	controller, err := New(opts...)
+	if err != nil {
+		fatalf("Failed to initialize controller: %v", err)
+	}
+	defer controller.Close()
+
+	logDeviceStrings(controller)
+
+	if *statsAddr != "" {
+		mux := http.NewServeMux()
+		mux.Handle("/stats", statsHandler(controller))
+		go func() {
+			if err := http.ListenAndServe(*statsAddr, mux); err != nil {
+				log.Printf("Stats endpoint stopped: %v", err)
+			}
+		}()
+	}
+
+	if err := controller.Initialize(); err != nil && !errors.Is(err, ErrReadOnly) {
+		fatalf("Failed to initialize: %v", err)
+	}
+
+	if *calibrate {
+		if *calibrationPath == "" {
+			fatalf("-calibrate needs -calibration to save to")
+		}
+		log.Println("Calibrating, leave the sticks alone...")
+		cal, err := controller.Calibrate(ctx, defaultCalibrationWindow)
+		if err != nil {
+			fatalf("Calibration failed: %v", err)
+		}
+		controller.SetCalibration(cal)
+		if err := controller.SaveCalibration(*calibrationPath); err != nil {
+			fatalf("%v", err)
+		}
+		for _, name := range stickAxes {
+			log.Printf("%s: offset %.3f, deadzone %.3f", name, cal[name].Offset, cal[name].Deadzone)
+		}
+		log.Printf("Saved calibration to %s", *calibrationPath)
+		return
+	}
+	if *calibrationPath != "" {
+		switch err := controller.LoadCalibration(*calibrationPath); {
+		case err == nil:
+			log.Printf("Loaded calibration from %s", *calibrationPath)
+		case errors.Is(err, os.ErrNotExist):
+			log.Printf("No saved calibration for this controller, run with -calibrate")
+		default:
+			fatalf("Failed to load calibration: %v", err)
+		}
+	}
+
+	if *reportCard {
+		ok, err := controller.runReportCard(ctx, os.Stdout)
+		if err != nil {
+			fatalf("Test failed: %v", err)
+		}
+		if !ok {
+			exitCode = 1
+		}
+		return
+	}
+
+	if *once {
+		state, err := controller.readOnce(ctx)
+		if err != nil {
+			fatalf("Failed to read state: %v", err)
+		}
+		switch stdout {
+		case "json":
+			err = newJSONOutput(os.Stdout).write(state, StateDiff{})
+		case "logfmt":
+			err = newLogfmtOutput(os.Stdout).write(state, StateDiff{})
+		default:
+			err = printState(os.Stdout, state)
+		}
+		if err != nil {
+			fatalf("Failed to print state: %v", err)
+		}
+		return
+	}
+	defer logButtonCounts(controller)
+
+	if autoFreq {
+		log.Println("Detecting report rate, move the sticks for a second...")
+		rate, err := controller.DetectReportRate(ctx, time.Second)
+		if err != nil {
+			log.Printf("Report rate detection failed, using %d Hz: %v", freq, err)
+		} else {
+			log.Printf("Detected report rate: %d Hz", rate)
+			freq = rate
+			controller.SetPollRate(rate)
+		}
+	}
+
+	if *blocking {
+		log.Println("Blocking on reports, no delay between reads")
+	} else {
+		log.Printf("Polling frequency set to %d Hz", freq)
+	}
+	log.Println("Xbox One controller connected and initialized")
+
+	var demo *triggerRumble
+	if *rumbleTriggers {
+		if *readonly {
+			fatalf("-rumble-triggers cannot be used with -readonly")
+		}
+		demo = &triggerRumble{c: controller}
+	}
+
+	var idle *time.Timer
+	if *idleExit > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithCancel(ctx)
+		defer cancel()
+		idle = time.AfterFunc(*idleExit, func() {
+			log.Printf("No input for %v, exiting", *idleExit)
+			cancel()
+		})
+		defer idle.Stop()
+	}
+
+	if tel != nil {
+		go func() {
+			if err := tel.run(ctx); err != nil {
+				log.Printf("Telemetry stopped: %v", err)
+			}
+		}()
+	}
+
+	err = controller.Run(ctx, func(state *ControllerState, diff StateDiff) error {
+		if idle != nil && !activity.filter(diff).Empty() {
+			idle.Reset(*idleExit)
+		}
+
+		if tel != nil {
+			tel.update(state)
+		}
+		if demo != nil {
+			if err := demo.update(state); err != nil {
+				log.Printf("Rumble failed: %v", err)
+			}
+		}
+
+		if dzView != nil {
+			dzView.print(controller, state)
+		} else {
+			fan.send(state, diff)
+		}
+		if *virtualPad && !*virtualPadRaw {
+			if err := pad.Update(state); err != nil {
+				log.Printf("Virtual gamepad: %v", err)
+			}
+		}
+		controller.playMacros(ctx, diff, func(ev Event) {
+			if edge.allows(ev.Type) {
+				logMacroEvent(ev)
+			}
+		})
+		return nil
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("Stopped: %v", err)
+	}
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestSetPollingFrequency(t *testing.T) {
+	tests := []struct {
+		hz   int
+		want time.Duration
+	}{
+		{-1, 16 * time.Millisecond},
+		{0, 16 * time.Millisecond},
+		{1, time.Second},
+		{60, 16666666 * time.Nanosecond},
+		{125, 8 * time.Millisecond},
+		{250, 4 * time.Millisecond},
+		{500, 2 * time.Millisecond},
+		{1000, time.Millisecond},
+		{8000, 125 * time.Microsecond},
+		{1e9, time.Nanosecond},
+		{2e9, 0},
+	}
+	for _, tt := range tests {
+		if got := setPollingFrequency(tt.hz); got != tt.want {
+			t.Errorf("setPollingFrequency(%d) = %v, want %v", tt.hz, got, tt.want)
+		}
+	}
+}
+
+// Rates that do not divide a second evenly must land within a nanosecond of
+// the exact period, not be rounded to whole milliseconds.
+func TestSetPollingFrequencyPrecision(t *testing.T) {
+	for _, hz := range []int{3, 7, 60, 144, 165, 240, 333, 1001} {
+		got := setPollingFrequency(hz)
+		exact := 1e9 / float64(hz)
+		if float64(got) > exact || exact-float64(got) >= 1 {
+			t.Errorf("setPollingFrequency(%d) = %dns, want within 1ns below %.3fns", hz, got.Nanoseconds(), exact)
+		}
+	}
+}
@@ -0,0 +1,139 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// MIDIMap sends buttons as notes and axes as control changes. Inputs that
+// are not mapped are not sent.
+type MIDIMap struct {
+	Notes map[string]byte
+	CCs   map[string]byte
+}
+
+// DefaultMIDIMap puts the buttons on notes 36-51 in ButtonNames order (the
+// usual drum pad range) and the axes on CC 20-25 in AxisNames order, which
+// no common controller assigns.
+func DefaultMIDIMap() MIDIMap {
+	m := MIDIMap{Notes: make(map[string]byte), CCs: make(map[string]byte)}
+	for i, name := range ButtonNames {
+		m.Notes[name] = byte(36 + i)
+	}
+	for i, name := range AxisNames {
+		m.CCs[name] = byte(20 + i)
+	}
+	return m
+}
+
+// ParseMIDIMap reads "BUTTON=note,AXIS=ccN,...", e.g. "A=60,B=62,RT=cc74",
+// with numbers 0-127.
+func ParseMIDIMap(spec string) (MIDIMap, error) {
+	m := MIDIMap{Notes: make(map[string]byte), CCs: make(map[string]byte)}
+	var s ControllerState
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, num, ok := strings.Cut(entry, "=")
+		if !ok {
+			return MIDIMap{}, fmt.Errorf("invalid MIDI mapping %q, expected INPUT=note or INPUT=ccN", entry)
+		}
+		name = strings.ToUpper(strings.TrimSpace(name))
+		num = strings.ToLower(strings.TrimSpace(num))
+
+		isAxis := s.axis(name) != nil
+		if !isAxis && s.button(name) == nil {
+			return MIDIMap{}, fmt.Errorf("MIDI mapping %q: unknown input %s; valid buttons are %s and valid axes are %s", entry, name, validButtons(), validAxes())
+		}
+		cc := strings.HasPrefix(num, "cc")
+		if cc != isAxis {
+			return MIDIMap{}, fmt.Errorf("MIDI mapping %q: buttons map to notes and axes to ccN", entry)
+		}
+
+		n, err := strconv.ParseUint(strings.TrimPrefix(num, "cc"), 10, 8)
+		if err != nil || n > 127 {
+			return MIDIMap{}, fmt.Errorf("MIDI mapping %q: number must be 0-127", entry)
+		}
+		if isAxis {
+			m.CCs[name] = byte(n)
+		} else {
+			m.Notes[name] = byte(n)
+		}
+	}
+	return m, nil
+}
+
+// midiCCValue maps sticks from -1..1 to 0..127 with the centre at 64, and
+// triggers from 0..1 to 0..127.
+func midiCCValue(name string, v float32) byte {
+	if !isTrigger(name) {
+		v = (v + 1) / 2
+	}
+	return byte(math.Max(0, math.Min(127, math.Round(float64(v)*127))))
+}
+
+// midiOutput writes raw MIDI bytes to a device such as an ALSA rawmidi port
+// (/dev/snd/midiC1D0; load snd-virmidi for a virtual one) or a pipe.
+type midiOutput struct {
+	f       *os.File
+	channel byte
+	m       MIDIMap
+	cc      map[string]byte
+}
+
+func newMIDIOutput(path string, channel int, m MIDIMap) (*midiOutput, error) {
+	if channel < 1 || channel > 16 {
+		return nil, fmt.Errorf("MIDI channel must be 1-16, got %d", channel)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening MIDI port failed: %v", err)
+	}
+	return &midiOutput{f: f, channel: byte(channel - 1), m: m, cc: make(map[string]byte)}, nil
+}
+
+func (o *midiOutput) Send(state *ControllerState, diff StateDiff) error {
+	var msg []byte
+	for _, name := range diff.Pressed {
+		if note, ok := o.m.Notes[name]; ok {
+			msg = append(msg, 0x90|o.channel, note, 127)
+		}
+	}
+	for _, name := range diff.Released {
+		if note, ok := o.m.Notes[name]; ok {
+			msg = append(msg, 0x80|o.channel, note, 0)
+		}
+	}
+
+	// Only send a CC when its 7-bit value changes, not on every report.
+	for _, name := range AxisNames {
+		cc, ok := o.m.CCs[name]
+		if !ok {
+			continue
+		}
+		v := midiCCValue(name, *state.axis(name))
+		if last, sent := o.cc[name]; sent && last == v {
+			continue
+		}
+		o.cc[name] = v
+		msg = append(msg, 0xb0|o.channel, cc, v)
+	}
+
+	if len(msg) == 0 {
+		return nil
+	}
+	if _, err := o.f.Write(msg); err != nil {
+		return fmt.Errorf("writing MIDI failed: %v", err)
+	}
+	return nil
+}
+
+func (o *midiOutput) Close() error {
+	return o.f.Close()
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"log"
+	"strings"
+
+	"github.com/google/gousb"
+)
+
+// ModelInfo describes one product ID the package recognizes. Model is
+// ModelAny for products that WithModel cannot select. Paddles, Share and
+// Battery describe the hardware; paddles and battery level are not decoded
+// yet.
+type ModelInfo struct {
+	Name      string
+	Model     Model
+	VendorID  gousb.ID
+	ProductID gousb.ID
+	Transport TransportKind
+
+	Paddles bool
+	Share   bool
+	Battery bool
+}
+
+// knownModels is the one list of recognized products; the USB and
+// Bluetooth product lists used for matching are derived from it.
+var knownModels = []ModelInfo{
+	{Name: "Xbox One Controller", Model: ModelXboxOne, ProductID: ProductXboxOne, Battery: true},
+	{Name: "Xbox One S Controller", Model: ModelXboxOneS, ProductID: ProductXboxOneS, Battery: true},
+	{Name: "Xbox One X Controller", Model: ModelXboxOneX, ProductID: ProductXboxOneX, Battery: true},
+	{Name: "Xbox Elite Controller", Model: ModelXboxElite, ProductID: ProductXboxElite, Paddles: true, Battery: true},
+	{Name: "Xbox Adaptive Controller", Model: ModelXboxAdaptive, ProductID: ProductXboxAdaptive, Battery: true},
+
+	{Name: "Xbox One S Controller", ProductID: ProductXboxOneSBluetooth, Transport: TransportBluetooth, Battery: true},
+	{Name: "Xbox One S Controller (old firmware)", ProductID: ProductXboxOneSBluetoothOld, Transport: TransportBluetooth, Battery: true},
+	{Name: "Xbox Series X|S Controller", ProductID: ProductXboxSeriesBluetooth, Transport: TransportBluetooth, Share: true, Battery: true},
+	{Name: "Xbox Elite Series 2 Controller", ProductID: ProductXboxElite2Bluetooth, Transport: TransportBluetooth, Paddles: true, Battery: true},
+}
+
+// SupportedModels lists every product the package can open, for showing
+// users which controllers are recognized.
+func SupportedModels() []ModelInfo {
+	models := make([]ModelInfo, len(knownModels))
+	for i, m := range knownModels {
+		m.VendorID = VendorMicrosoft
+		models[i] = m
+	}
+	return models
+}
+
+func logModelInfo(m ModelInfo) {
+	var features []string
+	if m.Paddles {
+		features = append(features, "paddles")
+	}
+	if m.Share {
+		features = append(features, "share")
+	}
+	if m.Battery {
+		features = append(features, "battery")
+	}
+	log.Printf("%04x:%04x %-10s %s (%s)", uint16(m.VendorID), uint16(m.ProductID), m.Transport, m.Name, strings.Join(features, ", "))
+}
+
+func knownProducts(t TransportKind) []gousb.ID {
+	var products []gousb.ID
+	for _, m := range knownModels {
+		if m.Transport == t {
+			products = append(products, m.ProductID)
+		}
+	}
+	return products
+}
+
+func knownModelProducts() map[Model]gousb.ID {
+	products := make(map[Model]gousb.ID)
+	for _, m := range knownModels {
+		if m.Model != ModelAny {
+			products[m.Model] = m.ProductID
+		}
+	}
+	return products
+}
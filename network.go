@@ -0,0 +1,68 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"time"
+)
+
+// Network packets are little-endian and fixed size:
+//
+//	[0:2]   magic "XB"
+//	[2]     version
+//	[3]     packet length in bytes
+//	[4:8]   sequence number
+//	[8:16]  capture time, Unix nanoseconds
+//	[16:18] buttons, ControllerState.ButtonMask
+//	[18]    source report ID
+//	[19:43] LT, RT, LEFTX, LEFTY, RIGHTX, RIGHTY as float32
+const (
+	networkVersion   = 1
+	networkPacketLen = 43
+)
+
+var networkMagic = [2]byte{'X', 'B'}
+
+func EncodeNetworkPacket(state *ControllerState, seq uint32, at time.Time) []byte {
+	buf := make([]byte, networkPacketLen)
+	copy(buf, networkMagic[:])
+	buf[2] = networkVersion
+	buf[3] = networkPacketLen
+	binary.LittleEndian.PutUint32(buf[4:8], seq)
+	binary.LittleEndian.PutUint64(buf[8:16], uint64(at.UnixNano()))
+
+	binary.LittleEndian.PutUint16(buf[16:18], uint16(state.ButtonMask()))
+	buf[18] = state.ReportID
+
+	for i, name := range AxisNames {
+		binary.LittleEndian.PutUint32(buf[19+4*i:], math.Float32bits(*state.axis(name)))
+	}
+	return buf
+}
+
+func DecodeNetworkPacket(buf []byte) (*ControllerState, uint32, time.Time, error) {
+	if len(buf) < 4 {
+		return nil, 0, time.Time{}, fmt.Errorf("short network packet: %d bytes", len(buf))
+	}
+	if buf[0] != networkMagic[0] || buf[1] != networkMagic[1] {
+		return nil, 0, time.Time{}, fmt.Errorf("not a controller packet: bad magic %q", buf[:2])
+	}
+	if buf[2] != networkVersion {
+		return nil, 0, time.Time{}, fmt.Errorf("unsupported network packet version %d", buf[2])
+	}
+	if int(buf[3]) != networkPacketLen || len(buf) < networkPacketLen {
+		return nil, 0, time.Time{}, fmt.Errorf("invalid network packet length: header says %d, got %d bytes, need %d", buf[3], len(buf), networkPacketLen)
+	}
+
+	seq := binary.LittleEndian.Uint32(buf[4:8])
+	at := time.Unix(0, int64(binary.LittleEndian.Uint64(buf[8:16])))
+
+	state := ButtonMaskToState(uint32(binary.LittleEndian.Uint16(buf[16:18])))
+	state.ReportID = buf[18]
+	state.Time = at
+	for i, name := range AxisNames {
+		*state.axis(name) = math.Float32frombits(binary.LittleEndian.Uint32(buf[19+4*i:]))
+	}
+	return state, seq, at, nil
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestNetworkPacketRoundTrip(t *testing.T) {
+	in := &ControllerState{
+		ReportID: reportInput,
+		A:        true, GUIDE: true, SHARE: true, LEFT: true,
+		LT: 0.25, RT: 1, LEFTX: -1, LEFTY: 0.5, RIGHTX: 0.125, RIGHTY: -0.75,
+	}
+	at := time.Unix(1700000000, 123456789)
+
+	out, seq, gotAt, err := DecodeNetworkPacket(EncodeNetworkPacket(in, 42, at))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if seq != 42 || !gotAt.Equal(at) || !out.Time.Equal(at) {
+		t.Fatalf("seq %d, time %v: want 42, %v", seq, gotAt, at)
+	}
+	if out.ButtonMask() != in.ButtonMask() || out.ReportID != in.ReportID {
+		t.Fatalf("buttons %v report 0x%02x, want %v 0x%02x", out.PressedButtons(), out.ReportID, in.PressedButtons(), in.ReportID)
+	}
+	for _, name := range AxisNames {
+		if got, want := *out.axis(name), *in.axis(name); got != want {
+			t.Errorf("%s = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestDecodeNetworkPacketRejects(t *testing.T) {
+	good := EncodeNetworkPacket(&ControllerState{}, 1, time.Now())
+	tests := map[string][]byte{
+		"empty":     {},
+		"truncated": good[:networkPacketLen-1],
+		"magic":     append([]byte{'X', 'X'}, good[2:]...),
+		"version":   append(append([]byte(nil), good[:2]...), append([]byte{networkVersion + 1}, good[3:]...)...),
+		"length":    append(append([]byte(nil), good[:3]...), append([]byte{networkPacketLen + 1}, good[4:]...)...),
+	}
+	for name, buf := range tests {
+		if _, _, _, err := DecodeNetworkPacket(buf); err == nil {
+			t.Errorf("%s packet decoded without an error", name)
+		}
+	}
+}
@@ -0,0 +1,77 @@
+//go:build notify
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"log"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+var notifyChords = flag.String("notify", "", "Desktop notifications for button chords, e.g. GUIDE+SHARE=Screenshot;LB+RB=Both bumpers")
+
+type chordNotification struct {
+	chord Chord
+	text  string
+}
+
+func parseNotifications(spec string) ([]chordNotification, error) {
+	var notes []chordNotification
+	for _, def := range strings.Split(spec, ";") {
+		def = strings.TrimSpace(def)
+		if def == "" {
+			continue
+		}
+		chordSpec, text, ok := strings.Cut(def, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid notification %q, expected CHORD=text", def)
+		}
+		chord, err := ParseChord(chordSpec)
+		if err != nil {
+			return nil, err
+		}
+		notes = append(notes, chordNotification{chord: chord, text: strings.TrimSpace(text)})
+	}
+	return notes, nil
+}
+
+func sendNotification(title, text string) error {
+	var cmd *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		cmd = exec.Command("osascript", "-e", fmt.Sprintf("display notification %q with title %q", text, title))
+	case "windows":
+		script := fmt.Sprintf(`[reflection.assembly]::loadwithpartialname('System.Windows.Forms') | Out-Null; `+
+			`$n = New-Object System.Windows.Forms.NotifyIcon; $n.Icon = [System.Drawing.SystemIcons]::Information; `+
+			`$n.Visible = $true; $n.ShowBalloonTip(5000, '%s', '%s', 'None'); Start-Sleep 5; $n.Dispose()`,
+			strings.ReplaceAll(title, "'", "''"), strings.ReplaceAll(text, "'", "''"))
+		cmd = exec.Command("powershell", "-NoProfile", "-Command", script)
+	default:
+		cmd = exec.Command("notify-send", title, text)
+	}
+	return cmd.Start()
+}
+
+func setupNotifications() (func(*ControllerState, StateDiff), error) {
+	if *notifyChords == "" {
+		return nil, nil
+	}
+
+	notes, err := parseNotifications(*notifyChords)
+	if err != nil {
+		return nil, err
+	}
+
+	return func(state *ControllerState, diff StateDiff) {
+		for _, n := range notes {
+			if n.chord.Completed(state, diff) {
+				if err := sendNotification("Xbox controller", n.text); err != nil {
+					log.Printf("Notification for %s failed: %v", n.chord, err)
+				}
+			}
+		}
+	}, nil
+}
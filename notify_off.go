@@ -0,0 +1,7 @@
+//go:build !notify
+
+package main
+
+func setupNotifications() (func(*ControllerState, StateDiff), error) {
+	return nil, nil
+}
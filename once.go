@@ -0,0 +1,47 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+const onceTimeout = 5 * time.Second
+
+// readOnce skips status and guide reports until a full input report arrives,
+// so the state it returns has every button and axis filled in.
+func (c *Controller) readOnce(ctx context.Context) (*ControllerState, error) {
+	ctx, cancel := context.WithTimeout(ctx, onceTimeout)
+	defer cancel()
+
+	for {
+		state, err := c.readState(ctx)
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				return nil, fmt.Errorf("no input report within %v", onceTimeout)
+			}
+			return nil, err
+		}
+		if c.isInputReport(state.ReportID) {
+			return state, nil
+		}
+	}
+}
+
+// printState writes the held buttons on one line ("none" if there are none)
+// and the axes on the next, e.g. for `xbox-controller -once | grep -qw A`.
+func printState(w io.Writer, state *ControllerState) error {
+	pressed := state.PressedButtons()
+	if len(pressed) == 0 {
+		pressed = []string{"none"}
+	}
+
+	axes := make([]string, len(AxisNames))
+	for i, name := range AxisNames {
+		axes[i] = fmt.Sprintf("%s=%.2f", name, *state.axis(name))
+	}
+	_, err := fmt.Fprintf(w, "%s\n%s\n", strings.Join(pressed, " "), strings.Join(axes, " "))
+	return err
+}
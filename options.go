@@ -0,0 +1,392 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+type Model int
+
+const (
+	ModelAny Model = iota
+	ModelXboxOne
+	ModelXboxOneS
+	ModelXboxOneX
+	ModelXboxElite
+	ModelXboxAdaptive
+)
+
+var modelProducts = knownModelProducts()
+
+func (m Model) String() string {
+	switch m {
+	case ModelAny:
+		return "any"
+	case ModelXboxOne:
+		return "Xbox One"
+	case ModelXboxOneS:
+		return "Xbox One S"
+	case ModelXboxOneX:
+		return "Xbox One X"
+	case ModelXboxElite:
+		return "Xbox Elite"
+	case ModelXboxAdaptive:
+		return "Xbox Adaptive Controller"
+	}
+	return "unknown"
+}
+
+func (m Model) products() []gousb.ID {
+	if pid, ok := modelProducts[m]; ok {
+		return []gousb.ID{pid}
+	}
+	return supportedProducts
+}
+
+type Config struct {
+	Deadzone  float32
+	PollRate  int
+	ReadOnly  bool
+	Model     Model
+	Layout    ButtonLayout
+	Transport TransportKind
+
+	ReportFormat ReportFormat
+
+	Interface   int
+	InEndpoint  int
+	OutEndpoint int
+
+	// InitPackets replaces the default 05 20 power-on command sent by
+	// Initialize.
+	InitPackets [][]byte
+
+	TurboButtons []string
+	TurboRate    int
+
+	LongPressButtons []string
+	LongPress        time.Duration
+
+	Macros map[string]Macro
+
+	Edges Edge
+
+	Remap *Remap
+
+	StickDpad          int
+	StickDpadThreshold float32
+	StickDpadRelease   float32
+
+	// TriggerDeadzone zeroes LT and RT below it, separately from the stick
+	// Deadzone.
+	TriggerDeadzone float32
+
+	// TriggerThreshold and TriggerRelease derive LTPressed and RTPressed. A
+	// zero release threshold, here and for StickDpadRelease, defaults to 80%
+	// of the press threshold.
+	TriggerThreshold float32
+	TriggerRelease   float32
+
+	// TriggerHoldTime is how long a trigger must stay past TriggerThreshold
+	// before LTPressed or RTPressed turns on. It is checked as reports
+	// arrive, so the press shows up on the first report after the time.
+	TriggerHoldTime time.Duration
+
+	Prediction time.Duration
+
+	AxisEvents bool
+
+	EventBuffer   int
+	EventOverflow Overflow
+
+	Reconnect bool
+
+	// RestoreOutputs re-applies the last rumble level after a reconnect.
+	RestoreOutputs bool
+
+	// Blocking drops the sleep between reads in Run, so each report is
+	// handled as soon as the interrupt endpoint delivers it and PollRate is
+	// ignored.
+	Blocking bool
+
+	LatencyBudget time.Duration
+
+	// Diagnostics warns about decoded input the hardware cannot produce.
+	Diagnostics bool
+
+	ClampSticks    bool
+	RadialDeadzone bool
+
+	Calibration Calibration
+
+	LeftSensitivity  float32
+	RightSensitivity float32
+
+	Capture *CaptureWriter
+
+	// Timer, if set, records the interval between successive input reports
+	// as they are read, restarting after a pause or reconnect.
+	Timer *ReportTimer
+
+	// IgnoreReports lists report IDs that are read and captured but
+	// otherwise dropped, leaving the state untouched.
+	IgnoreReports []byte
+
+	// OnReport sees every raw report before it is decoded.
+	OnReport func([]byte)
+}
+
+func DefaultConfig() Config {
+	return Config{
+		Deadzone: 0.1,
+		PollRate: 500,
+		Layout:   StandardLayout,
+
+		InEndpoint:  1,
+		OutEndpoint: 1,
+
+		TurboRate: 10,
+
+		LongPress: 500 * time.Millisecond,
+
+		EventBuffer: defaultEventBuffer,
+
+		RestoreOutputs: true,
+
+		StickDpadThreshold: 0.5,
+		TriggerDeadzone:    0.02,
+		TriggerThreshold:   0.5,
+
+		LeftSensitivity:  1,
+		RightSensitivity: 1,
+	}
+}
+
+// Config returns the configuration in effect, including changes made after
+// opening such as SetPollRate. Map and pointer fields are shared with the
+// controller and must not be modified.
+func (c *Controller) Config() Config {
+	return c.cfg
+}
+
+type Option func(*Controller)
+
+func WithDeadzone(deadzone float32) Option {
+	return func(c *Controller) {
+		c.cfg.Deadzone = deadzone
+	}
+}
+
+func WithPollRate(hz int) Option {
+	return func(c *Controller) {
+		c.cfg.PollRate = hz
+	}
+}
+
+func WithBlocking(blocking bool) Option {
+	return func(c *Controller) {
+		c.cfg.Blocking = blocking
+	}
+}
+
+func WithReadOnly(readOnly bool) Option {
+	return func(c *Controller) {
+		c.cfg.ReadOnly = readOnly
+	}
+}
+
+func WithModel(m Model) Option {
+	return func(c *Controller) {
+		c.cfg.Model = m
+	}
+}
+
+func WithButtonLayout(layout ButtonLayout) Option {
+	return func(c *Controller) {
+		c.cfg.Layout = layout
+	}
+}
+
+func WithTurbo(rate int, buttons ...string) Option {
+	return func(c *Controller) {
+		c.cfg.TurboRate = rate
+		c.cfg.TurboButtons = buttons
+	}
+}
+
+// WithLongPress reports each release of buttons as a tap or, when held for
+// at least d, a long press, e.g. WithLongPress(time.Second, "SHARE").
+func WithLongPress(d time.Duration, buttons ...string) Option {
+	return func(c *Controller) {
+		c.cfg.LongPress = d
+		c.cfg.LongPressButtons = buttons
+	}
+}
+
+func WithMacro(trigger string, steps ...MacroStep) Option {
+	return func(c *Controller) {
+		if c.cfg.Macros == nil {
+			c.cfg.Macros = make(map[string]Macro)
+		}
+		c.cfg.Macros[trigger] = steps
+	}
+}
+
+func WithMacros(macros map[string]Macro) Option {
+	return func(c *Controller) {
+		c.cfg.Macros = macros
+	}
+}
+
+// WithEndpoints overrides the USB interface and endpoint numbers used for
+// input and output. An out endpoint of 0 opens the controller read-only.
+func WithEndpoints(intf, in, out int) Option {
+	return func(c *Controller) {
+		c.cfg.Interface = intf
+		c.cfg.InEndpoint = in
+		c.cfg.OutEndpoint = out
+	}
+}
+
+func WithInitPackets(packets ...[]byte) Option {
+	return func(c *Controller) {
+		c.cfg.InitPackets = packets
+	}
+}
+
+func WithTransport(t TransportKind) Option {
+	return func(c *Controller) {
+		c.cfg.Transport = t
+	}
+}
+
+func WithReportFormat(f ReportFormat) Option {
+	return func(c *Controller) {
+		c.cfg.ReportFormat = f
+	}
+}
+
+func WithEdges(e Edge) Option {
+	return func(c *Controller) {
+		c.cfg.Edges = e
+	}
+}
+
+func WithRemap(r *Remap) Option {
+	return func(c *Controller) {
+		c.cfg.Remap = r
+	}
+}
+
+func WithStickDpad(ways int, threshold float32) Option {
+	return func(c *Controller) {
+		c.cfg.StickDpad = ways
+		c.cfg.StickDpadThreshold = threshold
+	}
+}
+
+func WithStickDpadRelease(threshold float32) Option {
+	return func(c *Controller) {
+		c.cfg.StickDpadRelease = threshold
+	}
+}
+
+func WithTriggerDeadzone(deadzone float32) Option {
+	return func(c *Controller) {
+		c.cfg.TriggerDeadzone = deadzone
+	}
+}
+
+func WithTriggerHoldTime(d time.Duration) Option {
+	return func(c *Controller) {
+		c.cfg.TriggerHoldTime = d
+	}
+}
+
+// WithTriggerThresholds sets where LTPressed and RTPressed turn on and,
+// lower, where they turn off again.
+func WithTriggerThresholds(press, release float32) Option {
+	return func(c *Controller) {
+		c.cfg.TriggerThreshold = press
+		c.cfg.TriggerRelease = release
+	}
+}
+
+func WithPrediction(horizon time.Duration) Option {
+	return func(c *Controller) {
+		c.cfg.Prediction = horizon
+	}
+}
+
+// WithRadialDeadzone switches from the per-axis deadzone to the scaled
+// radial one in StickVectorDeadzone, using the same Deadzone radius.
+func WithRadialDeadzone(radial bool) Option {
+	return func(c *Controller) {
+		c.cfg.RadialDeadzone = radial
+	}
+}
+
+func WithEventBuffer(size int, policy Overflow) Option {
+	return func(c *Controller) {
+		c.cfg.EventBuffer = size
+		c.cfg.EventOverflow = policy
+	}
+}
+
+func WithAxisEvents(enabled bool) Option {
+	return func(c *Controller) {
+		c.cfg.AxisEvents = enabled
+	}
+}
+
+func WithStickSensitivity(left, right float32) Option {
+	return func(c *Controller) {
+		c.cfg.LeftSensitivity = left
+		c.cfg.RightSensitivity = right
+	}
+}
+
+// SetStickSensitivity changes the linear stick multipliers at runtime. They
+// apply after the deadzone and before the unit-circle clamp.
+func (c *Controller) SetStickSensitivity(left, right float32) {
+	c.cfg.LeftSensitivity = left
+	c.cfg.RightSensitivity = right
+}
+
+func WithStickClamp(clamp bool) Option {
+	return func(c *Controller) {
+		c.cfg.ClampSticks = clamp
+	}
+}
+
+func WithCapture(w *CaptureWriter) Option {
+	return func(c *Controller) {
+		c.cfg.Capture = w
+	}
+}
+
+func WithReportTimer(t *ReportTimer) Option {
+	return func(c *Controller) {
+		c.cfg.Timer = t
+	}
+}
+
+func WithIgnoreReports(ids ...byte) Option {
+	return func(c *Controller) {
+		c.cfg.IgnoreReports = ids
+	}
+}
+
+func WithReportHook(fn func([]byte)) Option {
+	return func(c *Controller) {
+		c.cfg.OnReport = fn
+	}
+}
+
+func WithContext(ctx context.Context) Option {
+	return func(c *Controller) {
+		c.ctx = ctx
+	}
+}
@@ -0,0 +1,84 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"net"
+	"strings"
+)
+
+// oscSender sends Open Sound Control messages over UDP: one per button edge,
+// "<prefix>/button/A" with int 1 or 0, and one per changed axis,
+// "<prefix>/axis/leftx" with the float value.
+type oscSender struct {
+	conn   net.Conn
+	prefix string
+}
+
+func newOSCSender(addr, prefix string) (*oscSender, error) {
+	if !strings.HasPrefix(prefix, "/") {
+		return nil, fmt.Errorf("OSC prefix must start with /, got %q", prefix)
+	}
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("connecting to OSC target failed: %v", err)
+	}
+	return &oscSender{conn: conn, prefix: strings.TrimSuffix(prefix, "/")}, nil
+}
+
+func (o *oscSender) Send(state *ControllerState, diff StateDiff) error {
+	for _, name := range diff.Pressed {
+		if err := o.write(oscMessage(o.prefix+"/button/"+name, int32(1))); err != nil {
+			return err
+		}
+	}
+	for _, name := range diff.Released {
+		if err := o.write(oscMessage(o.prefix+"/button/"+name, int32(0))); err != nil {
+			return err
+		}
+	}
+	for _, name := range AxisNames {
+		v := *state.axis(name)
+		if state.LastState != nil && v == *state.LastState.axis(name) {
+			continue
+		}
+		if err := o.write(oscMessage(o.prefix+"/axis/"+strings.ToLower(name), v)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (o *oscSender) write(msg []byte) error {
+	if _, err := o.conn.Write(msg); err != nil {
+		return fmt.Errorf("sending OSC message failed: %v", err)
+	}
+	return nil
+}
+
+func (o *oscSender) Close() error {
+	return o.conn.Close()
+}
+
+// oscMessage encodes an address and one int32 or float32 argument. OSC
+// strings are NUL-terminated and padded to a multiple of 4 bytes, and
+// arguments are big-endian.
+func oscMessage(addr string, arg any) []byte {
+	msg := oscString(nil, addr)
+	var v uint32
+	switch a := arg.(type) {
+	case int32:
+		msg = oscString(msg, ",i")
+		v = uint32(a)
+	case float32:
+		msg = oscString(msg, ",f")
+		v = math.Float32bits(a)
+	}
+	return binary.BigEndian.AppendUint32(msg, v)
+}
+
+func oscString(buf []byte, s string) []byte {
+	buf = append(buf, s...)
+	return append(buf, make([]byte, 4-len(s)%4)...)
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type stateRecord struct {
+	Time     time.Time        `json:"time"`
+	Pressed  []string         `json:"pressed,omitempty"`
+	Released []string         `json:"released,omitempty"`
+	State    *ControllerState `json:"state"`
+}
+
+func newStateRecord(state *ControllerState, diff StateDiff) stateRecord {
+	return stateRecord{
+		Time:     time.Now(),
+		Pressed:  diff.Pressed,
+		Released: diff.Released,
+		State:    state,
+	}
+}
+
+type jsonOutput struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+func newJSONOutput(w io.Writer) *jsonOutput {
+	return &jsonOutput{enc: json.NewEncoder(w)}
+}
+
+func (o *jsonOutput) write(state *ControllerState, diff StateDiff) error {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return o.enc.Encode(newStateRecord(state, diff))
+}
+
+// logfmtOutput writes the same records as jsonOutput as key=value lines: one
+// per button edge, e.g. "ts=... event=press button=A", then one with every
+// axis if an analog value changed. A record without changes, as from -once,
+// is written as a single event=state line that also lists the held buttons.
+type logfmtOutput struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func newLogfmtOutput(w io.Writer) *logfmtOutput {
+	return &logfmtOutput{w: w}
+}
+
+func (o *logfmtOutput) write(state *ControllerState, diff StateDiff) error {
+	rec := newStateRecord(state, diff)
+	ts := "ts=" + rec.Time.Format(time.RFC3339Nano)
+
+	var b strings.Builder
+	for _, name := range rec.Pressed {
+		fmt.Fprintf(&b, "%s event=press button=%s\n", ts, name)
+	}
+	for _, name := range rec.Released {
+		fmt.Fprintf(&b, "%s event=release button=%s\n", ts, name)
+	}
+	if diff.Empty() {
+		buttons := strings.Join(state.PressedButtons(), ",")
+		if buttons == "" {
+			buttons = "none"
+		}
+		fmt.Fprintf(&b, "%s event=state buttons=%s%s\n", ts, buttons, logfmtAxes(state))
+	} else if diff.LeftStick || diff.RightStick || diff.Triggers {
+		fmt.Fprintf(&b, "%s event=axes%s\n", ts, logfmtAxes(state))
+	}
+
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	_, err := io.WriteString(o.w, b.String())
+	return err
+}
+
+func logfmtAxes(state *ControllerState) string {
+	var b strings.Builder
+	for _, name := range AxisNames {
+		b.WriteString(" " + name + "=" + strconv.FormatFloat(float64(*state.axis(name)), 'f', -1, 32))
+	}
+	return b.String()
+}
+
+// throttle passes button edges straight through but coalesces analog-only
+// changes so at most one is emitted per interval, always with the latest
+// state. A trailing timer flushes the last pending change.
+type throttle struct {
+	interval time.Duration
+	emit     func(*ControllerState, StateDiff)
+
+	mu      sync.Mutex
+	last    time.Time
+	pending *ControllerState
+	diff    StateDiff
+	timer   *time.Timer
+}
+
+func newThrottle(rate int, emit func(*ControllerState, StateDiff)) *throttle {
+	t := &throttle{emit: emit}
+	if rate > 0 {
+		t.interval = time.Second / time.Duration(rate)
+	}
+	return t
+}
+
+func (t *throttle) push(state *ControllerState, diff StateDiff) {
+	if diff.Empty() {
+		return
+	}
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	buttons := len(diff.Pressed) > 0 || len(diff.Released) > 0
+
+	if buttons || t.interval == 0 || now.Sub(t.last) >= t.interval {
+		t.flushLocked(state, t.merge(diff), now)
+		return
+	}
+
+	t.pending = state
+	t.diff = t.merge(diff)
+	if t.timer == nil {
+		t.timer = time.AfterFunc(t.last.Add(t.interval).Sub(now), t.fire)
+	}
+}
+
+func (t *throttle) merge(diff StateDiff) StateDiff {
+	if t.pending == nil {
+		return diff
+	}
+	diff.LeftStick = diff.LeftStick || t.diff.LeftStick
+	diff.RightStick = diff.RightStick || t.diff.RightStick
+	diff.Triggers = diff.Triggers || t.diff.Triggers
+	return diff
+}
+
+func (t *throttle) fire() {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.timer = nil
+	if t.pending != nil {
+		t.flushLocked(t.pending, t.diff, time.Now())
+	}
+}
+
+func (t *throttle) flushLocked(state *ControllerState, diff StateDiff, now time.Time) {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+	t.pending = nil
+	t.diff = StateDiff{}
+	t.last = now
+	t.emit(state, diff)
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"context"
+	"sync"
+)
+
+type pauser struct {
+	mu     sync.Mutex
+	paused bool
+	resync bool
+	resume chan struct{}
+	cancel context.CancelFunc
+}
+
+// wait blocks while paused and then returns a read context that Pause can
+// cancel, plus whether the loop must drop its last state before diffing.
+func (p *pauser) wait(ctx context.Context) (context.Context, bool, error) {
+	for {
+		p.mu.Lock()
+		if !p.paused {
+			readCtx, cancel := context.WithCancel(ctx)
+			p.cancel = cancel
+			resync := p.resync
+			p.resync = false
+			p.mu.Unlock()
+			return readCtx, resync, nil
+		}
+
+		if p.resume == nil {
+			p.resume = make(chan struct{})
+		}
+		resume := p.resume
+		p.mu.Unlock()
+
+		select {
+		case <-resume:
+		case <-ctx.Done():
+			return nil, false, ctx.Err()
+		}
+	}
+}
+
+func (p *pauser) done() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.cancel != nil {
+		p.cancel()
+		p.cancel = nil
+	}
+}
+
+func (c *Controller) Pause() {
+	c.pause.mu.Lock()
+	defer c.pause.mu.Unlock()
+
+	if c.pause.paused {
+		return
+	}
+	c.pause.paused = true
+	if c.pause.cancel != nil {
+		c.pause.cancel()
+	}
+}
+
+func (c *Controller) Resume() {
+	c.pause.mu.Lock()
+	defer c.pause.mu.Unlock()
+
+	if !c.pause.paused {
+		return
+	}
+	c.pause.paused = false
+	c.pause.resync = true
+	if c.pause.resume != nil {
+		close(c.pause.resume)
+		c.pause.resume = nil
+	}
+}
+
+func (c *Controller) Paused() bool {
+	c.pause.mu.Lock()
+	defer c.pause.mu.Unlock()
+	return c.pause.paused
+}
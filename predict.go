@@ -0,0 +1,62 @@
+package main
+
+import (
+	"time"
+)
+
+type stateSample struct {
+	state ControllerState
+	at    time.Time
+}
+
+func (c *Controller) recordSample(state *ControllerState, at time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.prevSample = c.curSample
+	c.curSample = stateSample{state: *state, at: at}
+	c.curSample.state.LastState = nil
+}
+
+// PredictedState extrapolates the stick axes linearly from the velocity
+// between the last two reports. The horizon is capped at the configured
+// prediction limit and results are clamped to [-1, 1]; with prediction
+// disabled the latest state is returned unchanged.
+func (c *Controller) PredictedState(at time.Time) *ControllerState {
+	c.mu.Lock()
+	cur, prev := c.curSample, c.prevSample
+	c.mu.Unlock()
+
+	state := cur.state
+	horizon := c.cfg.Prediction
+	if horizon <= 0 || prev.at.IsZero() || cur.at.IsZero() {
+		return &state
+	}
+
+	dt := cur.at.Sub(prev.at).Seconds()
+	ahead := at.Sub(cur.at)
+	if dt <= 0 || ahead <= 0 {
+		return &state
+	}
+	if ahead > horizon {
+		ahead = horizon
+	}
+	k := float32(ahead.Seconds() / dt)
+
+	extrapolate := func(v, last float32) float32 {
+		p := v + (v-last)*k
+		if p > 1 {
+			return 1
+		}
+		if p < -1 {
+			return -1
+		}
+		return p
+	}
+
+	state.LEFTX = extrapolate(cur.state.LEFTX, prev.state.LEFTX)
+	state.LEFTY = extrapolate(cur.state.LEFTY, prev.state.LEFTY)
+	state.RIGHTX = extrapolate(cur.state.RIGHTX, prev.state.RIGHTX)
+	state.RIGHTY = extrapolate(cur.state.RIGHTY, prev.state.RIGHTY)
+	return &state
+}
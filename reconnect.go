@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"time"
+)
+
+const (
+	reconnectMinBackoff = 100 * time.Millisecond
+	reconnectMaxBackoff = 5 * time.Second
+)
+
+func WithReconnect(enabled bool) Option {
+	return func(c *Controller) {
+		c.cfg.Reconnect = enabled
+	}
+}
+
+func WithRestoreOutputs(enabled bool) Option {
+	return func(c *Controller) {
+		c.cfg.RestoreOutputs = enabled
+	}
+}
+
+// release drops the device handles but keeps the libusb context, so the
+// same controller can be opened again.
+func (c *Controller) release() {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	if c.transport != nil {
+		c.transport.Close()
+		c.transport = nil
+	}
+}
+
+func (c *Controller) reopenDevice() error {
+	if c.cfg.Transport == TransportBluetooth {
+		return c.openBluetooth()
+	}
+	if c.usb == nil {
+		return fmt.Errorf("%w: reopening a caller-provided transport is not supported", ErrCannotReopen)
+	}
+	return c.open(c.usb)
+}
+
+// reconnect retries c.reopen with exponential backoff until it succeeds or
+// ctx ends, or gives up at once if the device can never be reopened. State
+// from before the disconnect is dropped so no button stays held across it.
+func (c *Controller) reconnect(ctx context.Context) error {
+	reopen := c.reopen
+	if reopen == nil {
+		reopen = c.reopenDevice
+	}
+
+	backoff := reconnectMinBackoff
+	for attempt := 1; ; attempt++ {
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		c.release()
+		err := reopen()
+		if err == nil {
+			c.Reset()
+			c.mu.Lock()
+			c.stats.reconnects++
+			c.mu.Unlock()
+
+			if err := c.Initialize(); err != nil && !errors.Is(err, ErrReadOnly) {
+				log.Printf("Failed to initialize after reconnect: %v", err)
+			}
+			if err := c.restoreOutputs(); err != nil && !errors.Is(err, ErrReadOnly) {
+				log.Printf("Failed to restore rumble after reconnect: %v", err)
+			}
+			log.Printf("Reconnected after %d attempts", attempt)
+			return nil
+		}
+
+		if errors.Is(err, ErrCannotReopen) {
+			return err
+		}
+		backoff = min(2*backoff, reconnectMaxBackoff)
+		log.Printf("Reconnect attempt %d failed, retrying in %v: %v", attempt, backoff, err)
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log"
+	"strings"
+	"testing"
+	"time"
+)
+
+var errStop = errors.New("stop")
+
+func TestReconnect(t *testing.T) {
+	before := &fakeTransport{}
+	before.queue(pressReport(), pressReport("A"))
+	before.queueErr(ErrDisconnected)
+	after := &fakeTransport{}
+	after.queue(pressReport())
+
+	c, err := NewFromTransport(before, WithReconnect(true), WithReadOnly(true), WithBlocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	var attempts []time.Time
+	c.reopen = func() error {
+		attempts = append(attempts, time.Now())
+		if len(attempts) < 2 {
+			return errors.New("not plugged in yet")
+		}
+		c.attach(after)
+		return nil
+	}
+
+	var logs bytes.Buffer
+	defer log.SetOutput(log.Writer())
+	log.SetOutput(&logs)
+
+	var disconnected time.Time
+	var states []*ControllerState
+	var diffs []StateDiff
+	err = c.Run(context.Background(), func(state *ControllerState, diff StateDiff) error {
+		states = append(states, state)
+		diffs = append(diffs, diff)
+		if len(states) == 2 {
+			disconnected = time.Now()
+		}
+		if len(states) == 3 {
+			return errStop
+		}
+		return nil
+	})
+	if !errors.Is(err, errStop) {
+		t.Fatalf("Run: %v", err)
+	}
+
+	if len(attempts) != 2 {
+		t.Fatalf("%d reopen attempts, want 2", len(attempts))
+	}
+	if wait := attempts[0].Sub(disconnected); wait < reconnectMinBackoff {
+		t.Errorf("first attempt after %v, want at least %v", wait, reconnectMinBackoff)
+	}
+	if wait := attempts[1].Sub(attempts[0]); wait < 2*reconnectMinBackoff {
+		t.Errorf("second attempt after %v, want at least %v", wait, 2*reconnectMinBackoff)
+	}
+	if before.closed != 1 {
+		t.Errorf("old transport closed %d times, want 1", before.closed)
+	}
+
+	// A was held at the disconnect; the fresh baseline must not carry it
+	// over or report it as released.
+	if states[2].A || states[2].LastState != nil || len(diffs[2].Pressed)+len(diffs[2].Released) != 0 {
+		t.Errorf("state after reconnect not reset: A=%v last=%v diff=%+v", states[2].A, states[2].LastState, diffs[2])
+	}
+	if n := c.Stats().Reconnects; n != 1 {
+		t.Errorf("Reconnects = %d, want 1", n)
+	}
+	if n := strings.Count(logs.String(), "Reconnected after 2 attempts"); n != 1 {
+		t.Errorf("reconnect logged %d times, want once:\n%s", n, logs.String())
+	}
+}
+
+func TestReconnectCannotReopen(t *testing.T) {
+	ft := &fakeTransport{}
+	ft.queue(pressReport())
+	ft.queueErr(ErrDisconnected)
+	c, err := NewFromTransport(ft, WithReconnect(true), WithReadOnly(true), WithBlocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	var last Event
+	for ev := range c.Events(ctx) {
+		last = ev
+	}
+	if ctx.Err() != nil {
+		t.Fatal("event stream kept retrying a transport that cannot be reopened")
+	}
+	if last.Type != EventDisconnect {
+		t.Fatalf("last event = %v, want EventDisconnect", last.Type)
+	}
+}
+
+func TestReconnectRegainsOutput(t *testing.T) {
+	before := &fakeTransport{noOut: true}
+	before.queue(pressReport())
+	before.queueErr(ErrDisconnected)
+	after := &fakeTransport{}
+	after.queue(pressReport())
+
+	c, err := NewFromTransport(before, WithReconnect(true), WithBlocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+	c.reopen = func() error {
+		c.attach(after)
+		return nil
+	}
+
+	if err := c.Initialize(); err != nil || len(before.written()) != 0 {
+		t.Fatalf("Initialize without an output: %v, %d writes", err, len(before.written()))
+	}
+	if err := c.SetRumble(Rumble{Strong: 1}); !errors.Is(err, ErrReadOnly) {
+		t.Fatalf("SetRumble without an output: got %v, want ErrReadOnly", err)
+	}
+
+	states := 0
+	c.Run(context.Background(), func(*ControllerState, StateDiff) error {
+		if states++; states == 2 {
+			return errStop
+		}
+		return nil
+	})
+	if w := after.written(); len(w) == 0 || !bytes.Equal(w[0], []byte{0x05, 0x20}) {
+		t.Fatalf("reopened transport with an output got writes % x, want the init packet", w)
+	}
+}
+
+func TestReadOnlyOptionSurvivesReconnect(t *testing.T) {
+	before := &fakeTransport{}
+	before.queue(pressReport())
+	before.queueErr(ErrDisconnected)
+	after := &fakeTransport{}
+	after.queue(pressReport())
+
+	c, err := NewFromTransport(before, WithReconnect(true), WithReadOnly(true), WithBlocking(true))
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.reopen = func() error {
+		c.attach(after)
+		return nil
+	}
+
+	states := 0
+	c.Run(context.Background(), func(*ControllerState, StateDiff) error {
+		if states++; states == 2 {
+			return errStop
+		}
+		return nil
+	})
+	c.Close()
+	if n := len(before.written()) + len(after.written()); n != 0 {
+		t.Fatalf("%d writes with WithReadOnly(true)", n)
+	}
+}
@@ -0,0 +1,228 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+type AxisMapping struct {
+	From   string
+	To     string
+	Scale  float32
+	Invert bool
+}
+
+type Remap struct {
+	Buttons map[string]string
+	Axes    []AxisMapping
+
+	// Shift names a modifier button that switches the buttons in Layer to
+	// their alternate destination while held. The modifier itself is
+	// consumed and never reported.
+	Shift string
+	Layer map[string]string
+}
+
+// ParseRemap reads "SRC=DST,..." where both sides are buttons or both are
+// axes. An axis destination may be prefixed with - to invert it and
+// suffixed with *scale, e.g. "A=B,B=A,RT=RIGHTY,LEFTX=-RIGHTX*1.5".
+// "MOD+SRC=DST" adds SRC to the shift layer of modifier MOD, e.g.
+// "LB+A=X,LB+B=Y"; all layer entries must share one modifier.
+func ParseRemap(spec string) (*Remap, error) {
+	r := &Remap{Buttons: make(map[string]string), Layer: make(map[string]string)}
+	var s ControllerState
+
+	for _, entry := range strings.Split(spec, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		from, to, ok := strings.Cut(entry, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid remap entry %q, expected SRC=DST", entry)
+		}
+		from = strings.ToUpper(strings.TrimSpace(from))
+		to = strings.ToUpper(strings.TrimSpace(to))
+
+		if mod, src, ok := strings.Cut(from, "+"); ok {
+			mod, src = strings.TrimSpace(mod), strings.TrimSpace(src)
+			for _, name := range []string{mod, src, to} {
+				if s.button(name) == nil {
+					return nil, fmt.Errorf("remap %q: shift layer entries must be MOD+BUTTON=BUTTON and %s is not a button; valid buttons are %s", entry, name, validButtons())
+				}
+			}
+			if r.Shift != "" && r.Shift != mod {
+				return nil, fmt.Errorf("remap %q: only one shift modifier is supported, already using %s", entry, r.Shift)
+			}
+			if src == mod {
+				return nil, fmt.Errorf("remap %q: %s cannot remap itself in its own layer", entry, mod)
+			}
+			r.Shift = mod
+			r.Layer[src] = to
+			continue
+		}
+
+		if s.button(from) != nil {
+			if s.button(to) == nil {
+				return nil, fmt.Errorf("remap %q: %s is a button and %s is not; valid buttons are %s", entry, from, to, validButtons())
+			}
+			r.Buttons[from] = to
+			continue
+		}
+
+		if s.axis(from) == nil {
+			return nil, fmt.Errorf("remap %q: unknown input %s; valid buttons are %s and valid axes are %s", entry, from, validButtons(), validAxes())
+		}
+
+		m := AxisMapping{From: from, Scale: 1}
+		if strings.HasPrefix(to, "-") {
+			m.Invert = true
+			to = to[1:]
+		}
+		if name, scale, ok := strings.Cut(to, "*"); ok {
+			v, err := strconv.ParseFloat(scale, 32)
+			if err != nil {
+				return nil, fmt.Errorf("remap %q: invalid scale: %v", entry, err)
+			}
+			if math.IsInf(v, 0) || math.IsNaN(v) {
+				return nil, fmt.Errorf("remap %q: scale must be a finite number", entry)
+			}
+			m.Scale = float32(v)
+			to = name
+		}
+		if s.axis(to) == nil {
+			return nil, fmt.Errorf("remap %q: %s is an axis and %s is not; valid axes are %s", entry, from, to, validAxes())
+		}
+		m.To = to
+		r.Axes = append(r.Axes, m)
+	}
+
+	return r, nil
+}
+
+// convertAxis maps a value between trigger (0..1) and stick (-1..1) ranges.
+// A trigger drives the positive half of a stick axis (the negative half when
+// inverted); a stick drives a trigger with its positive half (negative half
+// when inverted). Same-kind mappings negate a stick or flip a trigger to
+// 1-v when inverted. The result is scaled and clamped to the target range.
+func convertAxis(v float32, m AxisMapping) float32 {
+	fromTrigger, toTrigger := isTrigger(m.From), isTrigger(m.To)
+
+	switch {
+	case fromTrigger && toTrigger:
+		if m.Invert {
+			v = 1 - v
+		}
+	case fromTrigger:
+		if m.Invert {
+			v = -v
+		}
+	case toTrigger:
+		if m.Invert {
+			v = -v
+		}
+		if v < 0 {
+			v = 0
+		}
+	default:
+		if m.Invert {
+			v = -v
+		}
+	}
+
+	v *= m.Scale
+
+	lo := float32(-1)
+	if toTrigger {
+		lo = 0
+	}
+	if v < lo {
+		v = lo
+	}
+	if v > 1 {
+		v = 1
+	}
+	return v
+}
+
+// Apply moves remapped inputs to their destination. Sources are cleared, and
+// a destination fed by several inputs takes the pressed/largest of them.
+// While the shift modifier is held, Layer entries take precedence over
+// Buttons; other buttons keep their base mapping.
+func (r *Remap) Apply(s *ControllerState) {
+	r.apply(s, nil)
+}
+
+// apply with a latch decides the layer once per press: a button pressed
+// under shift keeps its layer destination until it is released, even if
+// the modifier is let go first, and vice versa. That keeps every output
+// press paired with its own release.
+func (r *Remap) apply(s *ControllerState, latch map[string]bool) {
+	if r == nil {
+		return
+	}
+	in := *s
+
+	shifted := false
+	if r.Shift != "" {
+		shifted = *in.button(r.Shift)
+		*s.button(r.Shift) = false
+	}
+
+	for from := range r.Buttons {
+		*s.button(from) = false
+	}
+	for from := range r.Layer {
+		*s.button(from) = false
+	}
+
+	route := func(from string) {
+		if !*in.button(from) {
+			delete(latch, from)
+			return
+		}
+
+		_, inLayer := r.Layer[from]
+		layered := shifted && inLayer
+		if latch != nil {
+			if v, ok := latch[from]; ok {
+				layered = v
+			} else {
+				latch[from] = layered
+			}
+		}
+
+		to := from
+		if dst, ok := r.Buttons[from]; ok {
+			to = dst
+		}
+		if layered {
+			to = r.Layer[from]
+		}
+		*s.button(to) = true
+	}
+	for from := range r.Buttons {
+		if from != r.Shift {
+			route(from)
+		}
+	}
+	for from := range r.Layer {
+		if _, ok := r.Buttons[from]; !ok {
+			route(from)
+		}
+	}
+
+	for _, m := range r.Axes {
+		*s.axis(m.From) = 0
+	}
+	for _, m := range r.Axes {
+		v := convertAxis(*in.axis(m.From), m)
+		dst := s.axis(m.To)
+		if math.Abs(float64(v)) > math.Abs(float64(*dst)) {
+			*dst = v
+		}
+	}
+}
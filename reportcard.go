@@ -0,0 +1,173 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"math"
+	"time"
+)
+
+const (
+	reportCardStepTimeout = 10 * time.Second
+
+	// reportCardFullScale is how far an axis must travel to count as fully
+	// deflected; worn sticks rarely reach exactly 1.
+	reportCardFullScale = 0.9
+)
+
+// reportCardCheck is one input to exercise. level maps the raw state to how
+// far along the input is, passing at 1.
+type reportCardCheck struct {
+	name  string
+	level func(*ControllerState) float32
+}
+
+type reportCardResult struct {
+	name   string
+	pass   bool
+	detail string
+}
+
+func reportCardChecks(share bool) []reportCardCheck {
+	var checks []reportCardCheck
+	for _, name := range ButtonNames {
+		if name == "SHARE" && !share {
+			continue
+		}
+		checks = append(checks, reportCardCheck{"press " + name, func(s *ControllerState) float32 {
+			if *s.button(name) {
+				return 1
+			}
+			return 0
+		}})
+	}
+
+	dirs := map[string][2]string{
+		"LEFTX": {"left", "right"}, "LEFTY": {"down", "up"},
+		"RIGHTX": {"left", "right"}, "RIGHTY": {"down", "up"},
+	}
+	for _, name := range stickAxes {
+		for i, sign := range []float32{-1, 1} {
+			stick := "left stick"
+			if name[0] == 'R' {
+				stick = "right stick"
+			}
+			checks = append(checks, reportCardCheck{"move " + stick + " fully " + dirs[name][i], func(s *ControllerState) float32 {
+				return sign * *s.axis(name) / reportCardFullScale
+			}})
+		}
+	}
+	for _, name := range []string{"LT", "RT"} {
+		checks = append(checks, reportCardCheck{"pull " + name + " fully", func(s *ControllerState) float32 {
+			return *s.axis(name) / reportCardFullScale
+		}})
+	}
+	return checks
+}
+
+// runReportCard checks the sticks for drift at rest, then prompts for every
+// button, stick direction and trigger in turn, ticking each off as the raw
+// decoded state shows it, and prints a summary. It reports whether every
+// check passed. A check that is not seen within reportCardStepTimeout fails.
+func (c *Controller) runReportCard(ctx context.Context, w io.Writer) (bool, error) {
+	var results []reportCardResult
+
+	fmt.Fprintf(w, "Leave the sticks and triggers alone... ")
+	drift, err := c.restCheck(ctx)
+	if err != nil {
+		return false, err
+	}
+	results = append(results, drift)
+	printReportCardResult(w, drift)
+
+	share := false
+	for _, m := range knownModels {
+		if m.ProductID == c.info.ProductID && m.Transport == c.info.Transport {
+			share = m.Share
+		}
+	}
+	for _, check := range reportCardChecks(share) {
+		fmt.Fprintf(w, "Now %s... ", check.name)
+		r, err := c.waitForCheck(ctx, check)
+		if err != nil {
+			return false, err
+		}
+		results = append(results, r)
+		printReportCardResult(w, r)
+	}
+
+	passed := 0
+	for _, r := range results {
+		if r.pass {
+			passed++
+		}
+	}
+	fmt.Fprintf(w, "\n%d of %d checks passed\n", passed, len(results))
+	for _, r := range results {
+		if !r.pass {
+			fmt.Fprintf(w, "  FAIL %s: %s\n", r.name, r.detail)
+		}
+	}
+	return passed == len(results), nil
+}
+
+func printReportCardResult(w io.Writer, r reportCardResult) {
+	if r.pass {
+		fmt.Fprintln(w, "ok")
+	} else {
+		fmt.Fprintf(w, "FAIL (%s)\n", r.detail)
+	}
+}
+
+// restCheck fails on buttons held with nothing touched and on sticks whose
+// resting offset is past the deadzone, i.e. drift that would leak through.
+func (c *Controller) restCheck(ctx context.Context) (reportCardResult, error) {
+	r := reportCardResult{name: "rest", pass: true}
+	cal, err := c.Calibrate(ctx, defaultCalibrationWindow)
+	if err != nil {
+		return r, err
+	}
+	if held := c.raw.PressedButtons(); len(held) > 0 {
+		r.pass = false
+		r.detail = fmt.Sprintf("%v held at rest", held)
+		return r, nil
+	}
+	for _, name := range stickAxes {
+		if off := cal[name].Offset; math.Abs(float64(off)) >= float64(c.cfg.Deadzone) {
+			r.pass = false
+			r.detail = fmt.Sprintf("%s drifts to %.2f at rest, past the %.2f deadzone", name, off, c.cfg.Deadzone)
+			return r, nil
+		}
+	}
+	return r, nil
+}
+
+func (c *Controller) waitForCheck(ctx context.Context, check reportCardCheck) (reportCardResult, error) {
+	r := reportCardResult{name: check.name}
+	stepCtx, cancel := context.WithTimeout(ctx, reportCardStepTimeout)
+	defer cancel()
+
+	var peak float32
+	for {
+		if _, err := c.readReport(stepCtx); err != nil {
+			if ctx.Err() != nil {
+				return r, ctx.Err()
+			}
+			if stepCtx.Err() != nil {
+				r.detail = fmt.Sprintf("not detected in %v", reportCardStepTimeout)
+				if peak > 0 {
+					r.detail = fmt.Sprintf("only reached %.0f%% of full travel", 100*peak*reportCardFullScale)
+				}
+				return r, nil
+			}
+			return r, err
+		}
+		level := check.level(&c.raw)
+		if level >= 1 {
+			r.pass = true
+			return r, nil
+		}
+		peak = max(peak, level)
+	}
+}
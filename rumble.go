@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"time"
+)
+
+type Rumble struct {
+	LeftTrigger  float32
+	RightTrigger float32
+	Strong       float32
+	Weak         float32
+}
+
+func rumbleLevel(v float32) byte {
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 100
+	}
+	return byte(math.Round(float64(v) * 100))
+}
+
+// GIP rumble command: 0x09, flags, sequence, payload length, then the motor
+// enable mask and per-motor magnitudes (0-100), on/off duration and repeat.
+func (c *Controller) rumblePacket(r Rumble) []byte {
+	c.seq++
+	return []byte{
+		0x09, 0x00, c.seq, 0x09,
+		0x00, 0x0f,
+		rumbleLevel(r.LeftTrigger), rumbleLevel(r.RightTrigger),
+		rumbleLevel(r.Strong), rumbleLevel(r.Weak),
+		0xff, 0x00, 0xff,
+	}
+}
+
+func (c *Controller) SetRumble(r Rumble) error {
+	c.CancelRumblePattern()
+	return c.setRumble(r)
+}
+
+func (c *Controller) setRumble(r Rumble) error {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+
+	// Kept even if the write fails, so restoreOutputs re-sends what was
+	// asked for last rather than what last got through.
+	c.rumble = r
+	return c.writeRumble(r)
+}
+
+// restoreOutputs re-sends the rumble level in effect before a reconnect,
+// which leaves the motors off. A pattern in progress carries on by itself
+// with its next frame.
+func (c *Controller) restoreOutputs() error {
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+
+	if !c.cfg.RestoreOutputs || c.rumble == (Rumble{}) {
+		return nil
+	}
+	return c.writeRumble(c.rumble)
+}
+
+// writeRumble must be called with outMu held.
+func (c *Controller) writeRumble(r Rumble) error {
+	packet := c.rumblePacket(r)
+	if c.bluetooth() {
+		packet = bluetoothRumblePacket(r)
+	}
+
+	if err := c.write(packet); err != nil {
+		return fmt.Errorf("setting rumble failed: %w", err)
+	}
+	return nil
+}
+
+func (c *Controller) StopRumble() error {
+	return c.SetRumble(Rumble{})
+}
+
+type RumbleFrame struct {
+	Rumble   Rumble
+	Duration time.Duration
+}
+
+type rumblePattern struct {
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+// RumblePattern plays the frames in order on a background goroutine and
+// stops the motors when it finishes or is cancelled. Starting a new pattern
+// or calling SetRumble cancels the one in progress.
+func (c *Controller) RumblePattern(pattern []RumbleFrame) error {
+	if len(pattern) == 0 {
+		return fmt.Errorf("rumble pattern is empty")
+	}
+	for i, f := range pattern {
+		if f.Duration <= 0 {
+			return fmt.Errorf("rumble frame %d has non-positive duration %v", i, f.Duration)
+		}
+	}
+
+	c.CancelRumblePattern()
+
+	ctx, cancel := context.WithCancel(c.ctx)
+	p := &rumblePattern{cancel: cancel, done: make(chan struct{})}
+
+	c.patternMu.Lock()
+	c.pattern = p
+	c.patternMu.Unlock()
+
+	go func() {
+		defer close(p.done)
+		defer cancel()
+		defer c.setRumble(Rumble{})
+
+		for _, f := range pattern {
+			// Keep time through a disconnect so the pattern resumes where
+			// it would have been once the controller is back.
+			if err := c.setRumble(f.Rumble); err != nil && !isDisconnect(err) {
+				log.Printf("Rumble pattern stopped: %v", err)
+				return
+			}
+
+			t := time.NewTimer(f.Duration)
+			select {
+			case <-t.C:
+			case <-ctx.Done():
+				t.Stop()
+				return
+			}
+		}
+	}()
+
+	return nil
+}
+
+func (c *Controller) CancelRumblePattern() {
+	c.patternMu.Lock()
+	p := c.pattern
+	c.pattern = nil
+	c.patternMu.Unlock()
+
+	if p != nil {
+		p.cancel()
+		<-p.done
+	}
+}
+
+type triggerRumble struct {
+	c      *Controller
+	lt, rt float32
+}
+
+func (t *triggerRumble) update(state *ControllerState) error {
+	const epsilon = 0.01
+	if math.Abs(float64(state.LT-t.lt)) < epsilon && math.Abs(float64(state.RT-t.rt)) < epsilon {
+		return nil
+	}
+
+	t.lt, t.rt = state.LT, state.RT
+	return t.c.SetRumble(Rumble{LeftTrigger: state.LT, RightTrigger: state.RT})
+}
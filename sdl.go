@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"log"
+	"math"
+	"time"
+)
+
+// SDLButton and SDLAxis use the numbering of SDL_GameControllerButton and
+// SDL_GameControllerAxis.
+type SDLButton int
+
+const (
+	SDLButtonA SDLButton = iota
+	SDLButtonB
+	SDLButtonX
+	SDLButtonY
+	SDLButtonBack
+	SDLButtonGuide
+	SDLButtonStart
+	SDLButtonLeftStick
+	SDLButtonRightStick
+	SDLButtonLeftShoulder
+	SDLButtonRightShoulder
+	SDLButtonDpadUp
+	SDLButtonDpadDown
+	SDLButtonDpadLeft
+	SDLButtonDpadRight
+	SDLButtonMisc1
+)
+
+type SDLAxis int
+
+const (
+	SDLAxisLeftX SDLAxis = iota
+	SDLAxisLeftY
+	SDLAxisRightX
+	SDLAxisRightY
+	SDLAxisTriggerLeft
+	SDLAxisTriggerRight
+)
+
+// SDLEventType values match SDL_CONTROLLERAXISMOTION, SDL_CONTROLLERBUTTONDOWN
+// and SDL_CONTROLLERBUTTONUP.
+type SDLEventType uint32
+
+const (
+	SDLControllerAxisMotion SDLEventType = 0x650
+	SDLControllerButtonDown SDLEventType = 0x651
+	SDLControllerButtonUp   SDLEventType = 0x652
+)
+
+var sdlButtons = map[string]SDLButton{
+	"A":     SDLButtonA,
+	"B":     SDLButtonB,
+	"X":     SDLButtonX,
+	"Y":     SDLButtonY,
+	"VIEW":  SDLButtonBack,
+	"GUIDE": SDLButtonGuide,
+	"MENU":  SDLButtonStart,
+	"LS":    SDLButtonLeftStick,
+	"RS":    SDLButtonRightStick,
+	"LB":    SDLButtonLeftShoulder,
+	"RB":    SDLButtonRightShoulder,
+	"UP":    SDLButtonDpadUp,
+	"DOWN":  SDLButtonDpadDown,
+	"LEFT":  SDLButtonDpadLeft,
+	"RIGHT": SDLButtonDpadRight,
+	"SHARE": SDLButtonMisc1,
+}
+
+var sdlAxes = map[string]SDLAxis{
+	"LEFTX":  SDLAxisLeftX,
+	"LEFTY":  SDLAxisLeftY,
+	"RIGHTX": SDLAxisRightX,
+	"RIGHTY": SDLAxisRightY,
+	"LT":     SDLAxisTriggerLeft,
+	"RT":     SDLAxisTriggerRight,
+}
+
+// SDLEvent carries Button for button events and Axis with Value for axis
+// motion. Which is the player index.
+type SDLEvent struct {
+	Type   SDLEventType
+	Time   time.Time
+	Which  int
+	Button SDLButton
+	Axis   SDLAxis
+	Value  int16
+}
+
+// sdlAxisValue converts to SDL's ranges: sticks span -32768..32767 with Y
+// growing downwards, triggers 0..32767.
+func sdlAxisValue(name string, v float32) int16 {
+	if name == "LEFTY" || name == "RIGHTY" {
+		v = -v
+	}
+	scaled := math.Round(float64(v) * 32767)
+	if isTrigger(name) {
+		scaled = math.Max(scaled, 0)
+	}
+	return int16(math.Max(-32768, math.Min(32767, scaled)))
+}
+
+func (c *Controller) sdlEvents(state *ControllerState, diff StateDiff, now time.Time) []SDLEvent {
+	diff = c.cfg.Edges.filter(diff)
+
+	// SDL has no trigger buttons, so LT and RT only go out as axes.
+	var events []SDLEvent
+	for _, name := range diff.Pressed {
+		if b, ok := sdlButtons[name]; ok {
+			events = append(events, SDLEvent{Type: SDLControllerButtonDown, Time: now, Which: c.player, Button: b})
+		}
+	}
+	for _, name := range diff.Released {
+		if b, ok := sdlButtons[name]; ok {
+			events = append(events, SDLEvent{Type: SDLControllerButtonUp, Time: now, Which: c.player, Button: b})
+		}
+	}
+
+	// SDL reports every axis change rather than threshold crossings.
+	for _, name := range AxisNames {
+		v := sdlAxisValue(name, *state.axis(name))
+		if state.LastState != nil && v == sdlAxisValue(name, *state.LastState.axis(name)) {
+			continue
+		}
+		events = append(events, SDLEvent{Type: SDLControllerAxisMotion, Time: now, Which: c.player, Axis: sdlAxes[name], Value: v})
+	}
+	return events
+}
+
+func (c *Controller) SDLEvents(ctx context.Context) <-chan SDLEvent {
+	ch := make(chan SDLEvent, c.cfg.EventBuffer)
+
+	go func() {
+		defer close(ch)
+		err := c.Run(ctx, func(state *ControllerState, diff StateDiff) error {
+			for _, ev := range c.sdlEvents(state, diff, state.Time) {
+				if err := sendEvent(ctx, ch, ev, c.cfg.EventOverflow, c.recordDroppedEvent); err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+		if err != nil && ctx.Err() == nil {
+			log.Printf("Player %d SDL event stream stopped: %v", c.player, err)
+		}
+	}()
+
+	return ch
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"context"
+	"log"
+	"sync"
+)
+
+// Sink is one destination for the state changes the CLI reports, such as
+// stdout, the pipe, OSC or MIDI.
+type Sink interface {
+	Send(state *ControllerState, diff StateDiff) error
+	Close() error
+}
+
+// sinkFunc is a Sink with nothing to close.
+type sinkFunc func(*ControllerState, StateDiff) error
+
+func (f sinkFunc) Send(state *ControllerState, diff StateDiff) error { return f(state, diff) }
+func (f sinkFunc) Close() error                                      { return nil }
+
+// filterSink passes changes through filter first, e.g. Edge.filter.
+type filterSink struct {
+	Sink
+	filter func(StateDiff) StateDiff
+}
+
+func (s filterSink) Send(state *ControllerState, diff StateDiff) error {
+	return s.Sink.Send(state, s.filter(diff))
+}
+
+type sinkUpdate struct {
+	state ControllerState
+	diff  StateDiff
+}
+
+type queuedSink struct {
+	name string
+	sink Sink
+	ch   chan sinkUpdate
+
+	dropped int
+}
+
+// fanout hands each change to every sink through a queue and goroutine of
+// its own, so a sink that stalls falls behind alone instead of holding up
+// the others or the read loop. A full queue drops its oldest change.
+type fanout struct {
+	sinks []*queuedSink
+	wg    sync.WaitGroup
+}
+
+func (f *fanout) add(name string, s Sink) {
+	q := &queuedSink{name: name, sink: s, ch: make(chan sinkUpdate, defaultEventBuffer)}
+	f.sinks = append(f.sinks, q)
+	f.wg.Add(1)
+	go func() {
+		defer f.wg.Done()
+		for u := range q.ch {
+			if err := s.Send(&u.state, u.diff); err != nil {
+				log.Printf("%s output failed: %v", name, err)
+			}
+		}
+	}()
+}
+
+func (f *fanout) send(state *ControllerState, diff StateDiff) {
+	for _, q := range f.sinks {
+		sendEvent(context.Background(), q.ch, sinkUpdate{*state, diff}, OverflowDropOldest, func() {
+			// Log the first drop and then every 100th, not each one.
+			if q.dropped%100 == 0 {
+				log.Printf("%s output is falling behind, dropping changes", q.name)
+			}
+			q.dropped++
+		})
+	}
+}
+
+// Close delivers what is still queued, then closes the sinks.
+func (f *fanout) Close() {
+	for _, q := range f.sinks {
+		close(q.ch)
+	}
+	f.wg.Wait()
+	for _, q := range f.sinks {
+		if err := q.sink.Close(); err != nil {
+			log.Printf("Closing %s output failed: %v", q.name, err)
+		}
+	}
+}
@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"sync"
+	"time"
+)
+
+// socketWriteTimeout bounds how long one stalled client can hold up the
+// others before it is disconnected.
+const socketWriteTimeout = 100 * time.Millisecond
+
+// socketServer sends the newline-delimited JSON of -format json to every
+// client connected to a Unix domain socket.
+type socketServer struct {
+	ln net.Listener
+
+	mu      sync.Mutex
+	clients map[net.Conn]bool
+}
+
+func newSocketServer(path string) (*socketServer, error) {
+	// A socket file left by a killed run makes Listen fail; replace it unless
+	// something still answers on it.
+	if fi, err := os.Lstat(path); err == nil && fi.Mode()&os.ModeSocket != 0 {
+		if conn, err := net.Dial("unix", path); err == nil {
+			conn.Close()
+			return nil, fmt.Errorf("%s is in use by another process", path)
+		}
+		os.Remove(path)
+	}
+
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		return nil, fmt.Errorf("listening on %s failed: %v", path, err)
+	}
+	s := &socketServer{ln: ln, clients: make(map[net.Conn]bool)}
+	go s.accept()
+	return s, nil
+}
+
+func (s *socketServer) accept() {
+	for {
+		conn, err := s.ln.Accept()
+		if errors.Is(err, net.ErrClosed) {
+			return
+		}
+		if err != nil {
+			log.Printf("Socket accept failed: %v", err)
+			continue
+		}
+		s.mu.Lock()
+		s.clients[conn] = true
+		s.mu.Unlock()
+	}
+}
+
+func (s *socketServer) Send(state *ControllerState, diff StateDiff) error {
+	if diff.Empty() {
+		return nil
+	}
+	line, err := json.Marshal(newStateRecord(state, diff))
+	if err != nil {
+		return err
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.SetWriteDeadline(time.Now().Add(socketWriteTimeout))
+		if _, err := conn.Write(line); err != nil {
+			conn.Close()
+			delete(s.clients, conn)
+		}
+	}
+	return nil
+}
+
+// Close disconnects the clients and removes the socket file.
+func (s *socketServer) Close() error {
+	err := s.ln.Close()
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for conn := range s.clients {
+		conn.Close()
+		delete(s.clients, conn)
+	}
+	return err
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"strings"
+)
+
+var ButtonNames = []string{"A", "B", "X", "Y", "RB", "LB", "UP", "RIGHT", "DOWN", "LEFT", "LS", "RS", "MENU", "VIEW", "GUIDE", "SHARE"}
+
+func (s *ControllerState) button(name string) *bool {
+	switch name {
+	case "A":
+		return &s.A
+	case "B":
+		return &s.B
+	case "X":
+		return &s.X
+	case "Y":
+		return &s.Y
+	case "RB":
+		return &s.RB
+	case "LB":
+		return &s.LB
+	case "UP":
+		return &s.UP
+	case "RIGHT":
+		return &s.RIGHT
+	case "DOWN":
+		return &s.DOWN
+	case "LEFT":
+		return &s.LEFT
+	case "LS":
+		return &s.LS
+	case "RS":
+		return &s.RS
+	case "MENU":
+		return &s.MENU
+	case "VIEW":
+		return &s.VIEW
+	case "GUIDE":
+		return &s.GUIDE
+	case "SHARE":
+		return &s.SHARE
+	}
+	return nil
+}
+
+// ButtonMask packs the buttons into bit i for ButtonNames[i]:
+//
+//	bit  0 A      bit  4 RB     bit  8 DOWN   bit 12 MENU
+//	bit  1 B      bit  5 LB     bit  9 LEFT   bit 13 VIEW
+//	bit  2 X      bit  6 UP     bit 10 LS     bit 14 GUIDE
+//	bit  3 Y      bit  7 RIGHT  bit 11 RS     bit 15 SHARE
+//
+// The remaining bits are reserved and always zero.
+func (s *ControllerState) ButtonMask() uint32 {
+	var mask uint32
+	for i, name := range ButtonNames {
+		if *s.button(name) {
+			mask |= 1 << i
+		}
+	}
+	return mask
+}
+
+func ButtonMaskToState(mask uint32) *ControllerState {
+	s := &ControllerState{}
+	for i, name := range ButtonNames {
+		*s.button(name) = mask&(1<<i) != 0
+	}
+	return s
+}
+
+func (s *ControllerState) AnyPressed() bool {
+	for _, name := range ButtonNames {
+		if *s.button(name) {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *ControllerState) PressedButtons() []string {
+	var pressed []string
+	for _, name := range ButtonNames {
+		if *s.button(name) {
+			pressed = append(pressed, name)
+		}
+	}
+	return pressed
+}
+
+var AxisNames = []string{"LT", "RT", "LEFTX", "LEFTY", "RIGHTX", "RIGHTY"}
+
+func (s *ControllerState) axis(name string) *float32 {
+	switch name {
+	case "LT":
+		return &s.LT
+	case "RT":
+		return &s.RT
+	case "LEFTX":
+		return &s.LEFTX
+	case "LEFTY":
+		return &s.LEFTY
+	case "RIGHTX":
+		return &s.RIGHTX
+	case "RIGHTY":
+		return &s.RIGHTY
+	}
+	return nil
+}
+
+// validButtons and validAxes go into parse errors, so a typo shows what
+// would have been accepted.
+func validButtons() string {
+	return strings.Join(ButtonNames, ", ")
+}
+
+func validAxes() string {
+	return strings.Join(AxisNames, ", ")
+}
+
+func isTrigger(name string) bool {
+	return name == "LT" || name == "RT"
+}
+
+// ScaledAxes maps the -1..1 stick axes linearly onto min..max, rounding to
+// the nearest integer and clamping, e.g. ScaledAxes(0, 255) for byte-sized
+// axes or ScaledAxes(-127, 127). Y keeps its sign convention: up is max.
+func (s *ControllerState) ScaledAxes(min, max int) (lx, ly, rx, ry int) {
+	scale := func(v float32) int {
+		f := math.Round(float64(min) + (float64(v)+1)/2*float64(max-min))
+		return int(math.Max(float64(min), math.Min(float64(max), f)))
+	}
+	return scale(s.LEFTX), scale(s.LEFTY), scale(s.RIGHTX), scale(s.RIGHTY)
+}
+
+// StateVectorLen is the length of ToVector's output: the 16 buttons in
+// ButtonNames order as 0 or 1, then the 6 axes in AxisNames order as
+// decoded. The order is fixed; new inputs would only ever be appended.
+const StateVectorLen = 22
+
+func (s *ControllerState) ToVector() []float32 {
+	v := make([]float32, 0, StateVectorLen)
+	for _, name := range ButtonNames {
+		if *s.button(name) {
+			v = append(v, 1)
+		} else {
+			v = append(v, 0)
+		}
+	}
+	for _, name := range AxisNames {
+		v = append(v, *s.axis(name))
+	}
+	return v
+}
+
+// FromVector is the inverse of ToVector. Button entries of 0.5 or more count
+// as pressed, so rounded model outputs can be fed back directly.
+func FromVector(v []float32) (*ControllerState, error) {
+	if len(v) != StateVectorLen {
+		return nil, fmt.Errorf("state vector must have %d entries, got %d", StateVectorLen, len(v))
+	}
+	s := &ControllerState{}
+	for i, name := range ButtonNames {
+		*s.button(name) = v[i] >= 0.5
+	}
+	for i, name := range AxisNames {
+		*s.axis(name) = v[len(ButtonNames)+i]
+	}
+	return s, nil
+}
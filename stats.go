@@ -0,0 +1,167 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/bits"
+	"net/http"
+	"strings"
+	"time"
+)
+
+type Stats struct {
+	Uptime     time.Duration
+	Reads      uint64
+	Errors     uint64
+	Dropped    uint64
+	Reconnects uint64
+	Rate       float64
+
+	DroppedEvents uint64
+
+	// OverBudget and MaxLatency are only tracked with WithLatencyBudget.
+	OverBudget uint64
+	MaxLatency time.Duration
+
+	// Presses counts physical presses per button, before remapping, stick
+	// d-pad emulation, turbo or macros.
+	Presses map[string]uint64
+}
+
+type controllerStats struct {
+	opened     time.Time
+	reads      uint64
+	errors     uint64
+	dropped    uint64
+	reconnects uint64
+	presses    map[string]uint64
+
+	lastPressed map[string]time.Time
+
+	droppedEvents uint64
+
+	overBudget    uint64
+	maxLatency    time.Duration
+	latencyLogged time.Time
+}
+
+func (c *Controller) markOpened(now time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.lastRead = now
+	c.stats.opened = now
+}
+
+// recordPresses counts the buttons held in the decoded report mask now but
+// not before, ahead of any remapping.
+func (c *Controller) recordPresses(before, now uint32, at time.Time) {
+	pressed := now &^ before
+	if pressed == 0 {
+		return
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.stats.presses == nil {
+		c.stats.presses = make(map[string]uint64)
+		c.stats.lastPressed = make(map[string]time.Time)
+	}
+	for ; pressed != 0; pressed &= pressed - 1 {
+		name := ButtonNames[bits.TrailingZeros32(pressed)]
+		c.stats.presses[name]++
+		c.stats.lastPressed[name] = at
+	}
+}
+
+// LastPressed returns when the named button was last pressed, as the Time of
+// the report that pressed it, or the zero time if it has not been pressed
+// since the controller was opened. Like Stats it counts physical presses,
+// not remapped buttons, turbo repeats or macros, and is safe to call from
+// any goroutine.
+func (c *Controller) LastPressed(name string) time.Time {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.stats.lastPressed[name]
+}
+
+func (c *Controller) recordDrop() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.stats.dropped++
+}
+
+// Stats counts since the controller was opened. Rate is the average
+// successful read rate over that time; Dropped counts reports that were
+// read but could not be decoded.
+func (c *Controller) Stats() Stats {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	s := Stats{
+		Reads:      c.stats.reads,
+		Errors:     c.stats.errors,
+		Dropped:    c.stats.dropped,
+		Reconnects: c.stats.reconnects,
+		Presses:    make(map[string]uint64, len(c.stats.presses)),
+
+		DroppedEvents: c.stats.droppedEvents,
+
+		OverBudget: c.stats.overBudget,
+		MaxLatency: c.stats.maxLatency,
+	}
+	for name, n := range c.stats.presses {
+		s.Presses[name] = n
+	}
+	if !c.stats.opened.IsZero() {
+		s.Uptime = time.Since(c.stats.opened)
+	}
+	if s.Uptime > 0 {
+		s.Rate = float64(s.Reads) / s.Uptime.Seconds()
+	}
+	return s
+}
+
+func (s Stats) String() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "uptime %v\n", s.Uptime.Round(time.Second))
+	fmt.Fprintf(&b, "reads %d\n", s.Reads)
+	fmt.Fprintf(&b, "errors %d\n", s.Errors)
+	fmt.Fprintf(&b, "dropped %d\n", s.Dropped)
+	fmt.Fprintf(&b, "reconnects %d\n", s.Reconnects)
+	fmt.Fprintf(&b, "rate %.1f Hz\n", s.Rate)
+	fmt.Fprintf(&b, "dropped events %d\n", s.DroppedEvents)
+	fmt.Fprintf(&b, "over budget %d\n", s.OverBudget)
+	fmt.Fprintf(&b, "max latency %v\n", s.MaxLatency)
+	if presses := s.pressSummary(); presses != "" {
+		fmt.Fprintf(&b, "presses %s\n", presses)
+	}
+	return b.String()
+}
+
+func (s Stats) pressSummary() string {
+	var parts []string
+	for _, name := range ButtonNames {
+		if n := s.Presses[name]; n > 0 {
+			parts = append(parts, fmt.Sprintf("%s=%d", name, n))
+		}
+	}
+	return strings.Join(parts, " ")
+}
+
+func logButtonCounts(c *Controller) {
+	if presses := c.Stats().pressSummary(); presses != "" {
+		log.Printf("Button presses: %s", presses)
+	} else {
+		log.Println("Button presses: none")
+	}
+}
+
+func statsHandler(c *Controller) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		fmt.Fprint(w, c.Stats())
+	})
+}
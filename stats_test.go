@@ -0,0 +1,35 @@
+package main
+
+import "testing"
+
+func TestPressesCountedBeforeRemap(t *testing.T) {
+	remap, err := ParseRemap("A=B")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ft := &fakeTransport{}
+	ft.queue(pressReport(), pressReport("A"), pressReport("A"), pressReport(), pressReport("A"))
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithRemap(remap))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	for i := 0; i < 5; i++ {
+		state, _, err := c.ReadStateDiff()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if state.A {
+			t.Fatal("A reported although remapped to B")
+		}
+	}
+
+	presses := c.Stats().Presses
+	if presses["A"] != 2 || presses["B"] != 0 {
+		t.Fatalf("Presses = %v, want A pressed twice and B never", presses)
+	}
+	if c.LastPressed("A").IsZero() || !c.LastPressed("B").IsZero() {
+		t.Fatalf("LastPressed A %v, B %v", c.LastPressed("A"), c.LastPressed("B"))
+	}
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// telemetry writes the analog axes at a fixed rate whether or not they
+// changed, for analysing stick movement over time: CSV rows under a header,
+// or one JSON object per line.
+type telemetry struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	latest ControllerState
+	seen   bool
+
+	csv  *csv.Writer
+	json *json.Encoder
+}
+
+type telemetrySample struct {
+	Time   time.Time `json:"time"`
+	LT     float32   `json:"LT"`
+	RT     float32   `json:"RT"`
+	LEFTX  float32   `json:"LEFTX"`
+	LEFTY  float32   `json:"LEFTY"`
+	RIGHTX float32   `json:"RIGHTX"`
+	RIGHTY float32   `json:"RIGHTY"`
+}
+
+func newTelemetry(w io.Writer, format string, hz int) (*telemetry, error) {
+	if hz <= 0 {
+		return nil, fmt.Errorf("telemetry rate must be positive, got %d", hz)
+	}
+	t := &telemetry{interval: time.Second / time.Duration(hz)}
+	switch format {
+	case "csv":
+		t.csv = csv.NewWriter(w)
+		return t, t.csv.Write(append([]string{"time"}, AxisNames...))
+	case "json":
+		t.json = json.NewEncoder(w)
+		return t, nil
+	}
+	return nil, fmt.Errorf("unknown telemetry format %q, expected csv or json", format)
+}
+
+func (t *telemetry) update(state *ControllerState) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.latest = *state
+	t.seen = true
+}
+
+// run writes a sample every interval until ctx ends, starting once the
+// first state has arrived.
+func (t *telemetry) run(ctx context.Context) error {
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case now := <-ticker.C:
+			t.mu.Lock()
+			s, seen := t.latest, t.seen
+			t.mu.Unlock()
+			if !seen {
+				continue
+			}
+			if err := t.write(now, &s); err != nil {
+				return err
+			}
+		}
+	}
+}
+
+func (t *telemetry) write(now time.Time, s *ControllerState) error {
+	if t.json != nil {
+		return t.json.Encode(telemetrySample{now, s.LT, s.RT, s.LEFTX, s.LEFTY, s.RIGHTX, s.RIGHTY})
+	}
+	row := []string{now.Format(time.RFC3339Nano)}
+	for _, name := range AxisNames {
+		row = append(row, strconv.FormatFloat(float64(*s.axis(name)), 'f', -1, 32))
+	}
+	t.csv.Write(row)
+	t.csv.Flush()
+	return t.csv.Error()
+}
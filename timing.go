@@ -0,0 +1,197 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"math"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	timingBucketWidth = 100 * time.Microsecond
+	timingBuckets     = 500
+)
+
+type ReportTimer struct {
+	last    time.Time
+	count   int
+	total   time.Duration
+	min     time.Duration
+	max     time.Duration
+	buckets [timingBuckets + 1]int
+}
+
+func (t *ReportTimer) Record(now time.Time) {
+	if t.last.IsZero() {
+		t.last = now
+		return
+	}
+
+	delta := now.Sub(t.last)
+	t.last = now
+
+	if t.count == 0 || delta < t.min {
+		t.min = delta
+	}
+	if delta > t.max {
+		t.max = delta
+	}
+	t.count++
+	t.total += delta
+
+	i := int(delta / timingBucketWidth)
+	if i > timingBuckets {
+		i = timingBuckets
+	}
+	t.buckets[i]++
+}
+
+// restart drops the previous report time so a gap in reading, such as a
+// pause or reconnect, is not counted as an interval.
+func (t *ReportTimer) restart() {
+	if t != nil {
+		t.last = time.Time{}
+	}
+}
+
+func (t *ReportTimer) Count() int {
+	return t.count
+}
+
+func (t *ReportTimer) Mean() time.Duration {
+	if t.count == 0 {
+		return 0
+	}
+	return t.total / time.Duration(t.count)
+}
+
+func (t *ReportTimer) Percentile(p float64) time.Duration {
+	if t.count == 0 {
+		return 0
+	}
+
+	target := int(p / 100 * float64(t.count))
+	if target >= t.count {
+		target = t.count - 1
+	}
+
+	seen := 0
+	for i, n := range t.buckets {
+		seen += n
+		if seen > target {
+			if i == timingBuckets {
+				return t.max
+			}
+			return time.Duration(i) * timingBucketWidth
+		}
+	}
+	return t.max
+}
+
+func (t *ReportTimer) Summary() string {
+	if t.count == 0 {
+		return "no report intervals recorded"
+	}
+
+	var b strings.Builder
+	mean := t.Mean()
+	fmt.Fprintf(&b, "%d intervals, mean %v (%.1f Hz), min %v, max %v\n", t.count, mean, float64(time.Second)/float64(mean), t.min, t.max)
+	fmt.Fprintf(&b, "p50 %v, p90 %v, p99 %v, p99.9 %v\n", t.Percentile(50), t.Percentile(90), t.Percentile(99), t.Percentile(99.9))
+
+	peak := 0
+	for _, n := range t.buckets {
+		if n > peak {
+			peak = n
+		}
+	}
+
+	for i, n := range t.buckets {
+		if n == 0 {
+			continue
+		}
+		label := fmt.Sprintf("%6.1fms", float64(time.Duration(i)*timingBucketWidth)/float64(time.Millisecond))
+		if i == timingBuckets {
+			label = fmt.Sprintf(">%5.0fms", float64(timingBuckets*timingBucketWidth)/float64(time.Millisecond))
+		}
+		fmt.Fprintf(&b, "%s %-40s %d\n", label, strings.Repeat("#", 1+n*39/peak), n)
+	}
+
+	return b.String()
+}
+
+func logReportTiming(t *ReportTimer) {
+	for _, line := range strings.Split(strings.TrimRight(t.Summary(), "\n"), "\n") {
+		log.Printf("Report timing: %s", line)
+	}
+}
+
+const minDetectIntervals = 10
+
+// standardReportRates are the USB polling rates controllers run at.
+var standardReportRates = []int{125, 250, 500, 1000}
+
+// DetectReportRate reads reports for the given window and returns the
+// controller's native rate: the median interval between input reports,
+// rounded to the nearest of standardReportRates. Controllers only report
+// while input changes, so the sticks need to be moving meanwhile.
+func (c *Controller) DetectReportRate(ctx context.Context, window time.Duration) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, window)
+	defer cancel()
+
+	var intervals []time.Duration
+	var last time.Time
+	for ctx.Err() == nil {
+		state, err := c.readState(ctx)
+		if err != nil {
+			if ctx.Err() != nil {
+				break
+			}
+			return 0, err
+		}
+		if !c.isInputReport(state.ReportID) {
+			continue
+		}
+		if !last.IsZero() {
+			intervals = append(intervals, state.Time.Sub(last))
+		}
+		last = state.Time
+	}
+
+	if len(intervals) < minDetectIntervals {
+		return 0, fmt.Errorf("only %d reports in %v, move the sticks while detecting", len(intervals)+1, window)
+	}
+	return nearestReportRate(medianInterval(intervals)), nil
+}
+
+func medianInterval(intervals []time.Duration) time.Duration {
+	sorted := slices.Clone(intervals)
+	slices.Sort(sorted)
+	n := len(sorted)
+	if n%2 == 1 {
+		return sorted[n/2]
+	}
+	return (sorted[n/2-1] + sorted[n/2]) / 2
+}
+
+// nearestReportRate picks the standard rate closest to the one interval
+// implies, comparing ratios so 180Hz is nearer 250 than 125.
+func nearestReportRate(interval time.Duration) int {
+	if interval <= 0 {
+		return standardReportRates[len(standardReportRates)-1]
+	}
+	hz := float64(time.Second) / float64(interval)
+	best := standardReportRates[0]
+	for _, rate := range standardReportRates[1:] {
+		if math.Abs(math.Log(hz/float64(rate))) < math.Abs(math.Log(hz/float64(best))) {
+			best = rate
+		}
+	}
+	return best
+}
+
+func (c *Controller) SetPollRate(hz int) {
+	c.cfg.PollRate = hz
+}
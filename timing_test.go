@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestReportTimerCountsOnlyReads(t *testing.T) {
+	var timer ReportTimer
+	ft := &fakeTransport{}
+	ft.queue(pressReport(), pressReport("A"), []byte{reportGuide, 0x20, 0x01, 0x02, 0x01})
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithBlocking(true), WithTurbo(50, "A"), WithReportTimer(&timer))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// Turbo keeps calling back for 100ms after the last report; none of
+	// those ticks, nor the guide report, is a report interval.
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	calls := 0
+	c.Run(ctx, func(*ControllerState, StateDiff) error {
+		calls++
+		return nil
+	})
+	if calls <= 3 {
+		t.Fatalf("only %d callbacks, turbo did not tick", calls)
+	}
+	if n := timer.Count(); n != 1 {
+		t.Fatalf("%d intervals recorded from 2 input reports, want 1", n)
+	}
+
+	// A reconnect resets the controller; the gap across it is not counted.
+	c.Reset()
+	time.Sleep(20 * time.Millisecond)
+	ft.queue(pressReport())
+	if _, err := c.ReadState(); err != nil {
+		t.Fatal(err)
+	}
+	if n := timer.Count(); n != 1 || timer.max >= 20*time.Millisecond {
+		t.Fatalf("after Reset: %d intervals, max %v", n, timer.max)
+	}
+}
+
+func TestNearestReportRate(t *testing.T) {
+	us := time.Microsecond
+	tests := []struct {
+		interval time.Duration
+		want     int
+	}{
+		{7950 * us, 125}, // 125.8Hz, which the bucket floor read as 127Hz
+		{8 * time.Millisecond, 125},
+		{20 * time.Millisecond, 125},
+		{5500 * us, 250}, // 182Hz is nearer 250 than 125 by ratio
+		{4 * time.Millisecond, 250},
+		{2100 * us, 500},
+		{1 * time.Millisecond, 1000},
+		{50 * us, 1000}, // not clamped up to 10kHz
+		{0, 1000},
+	}
+	for _, tt := range tests {
+		if got := nearestReportRate(tt.interval); got != tt.want {
+			t.Errorf("nearestReportRate(%v) = %d, want %d", tt.interval, got, tt.want)
+		}
+	}
+}
+
+func TestMedianInterval(t *testing.T) {
+	ms := time.Millisecond
+	if got := medianInterval([]time.Duration{9 * ms, 1 * ms, 8 * ms}); got != 8*ms {
+		t.Errorf("odd median = %v, want 8ms", got)
+	}
+	in := []time.Duration{8 * ms, 1 * ms, 7 * ms, 30 * ms}
+	if got := medianInterval(in); got != 7500*time.Microsecond {
+		t.Errorf("even median = %v, want 7.5ms", got)
+	}
+	if in[0] != 8*ms {
+		t.Error("medianInterval sorted its argument")
+	}
+}
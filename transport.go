@@ -0,0 +1,138 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/gousb"
+)
+
+// Transport carries raw reports to and from one controller. Controller only
+// talks to the device through it, so a backend such as XInput or IOKit plugs
+// in by implementing it and passing it to NewFromTransport. Info().Transport
+// tells Controller which report format to decode.
+type Transport interface {
+	ReadReport(ctx context.Context, buf []byte) (int, error)
+	// WriteReport sends the whole report or fails; a short write is an
+	// error wrapping io.ErrShortWrite.
+	WriteReport(report []byte) error
+	Close() error
+
+	Info() ControllerInfo
+	Strings() (DeviceStrings, error)
+}
+
+// writableTransport is implemented by transports that can open without a
+// way to send, such as a USB interface with no OUT endpoint or a hidraw node
+// without write permission. That is a property of the current connection,
+// so it is asked of the transport rather than recorded in Config.ReadOnly.
+type writableTransport interface {
+	canWrite() bool
+}
+
+// readOnly reports whether nothing may be sent, either because the caller
+// asked for it or because the current transport cannot.
+func (c *Controller) readOnly() bool {
+	if c.cfg.ReadOnly {
+		return true
+	}
+	w, ok := c.transport.(writableTransport)
+	return ok && !w.canWrite()
+}
+
+type DeviceStrings struct {
+	Manufacturer string
+	Product      string
+	Serial       string
+}
+
+// NewFromTransport drives a controller over an already opened transport.
+// Reconnecting needs a way to reopen the device, so with WithReconnect, Run
+// ends with ErrCannotReopen when the transport goes away.
+func NewFromTransport(t Transport, opts ...Option) (*Controller, error) {
+	c, err := newController(opts)
+	if err != nil {
+		return nil, err
+	}
+	c.cfg.Transport = t.Info().Transport
+	c.attach(t)
+	if s, err := t.Strings(); err == nil {
+		c.serial = s.Serial
+	}
+	return c, nil
+}
+
+func (c *Controller) attach(t Transport) {
+	c.outMu.Lock()
+	c.transport = t
+	c.outMu.Unlock()
+	c.info = t.Info()
+	c.markOpened(time.Now())
+}
+
+func (c *Controller) bluetooth() bool {
+	return c.info.Transport == TransportBluetooth
+}
+
+// usbTransport is the gousb backend: interrupt transfers on one interface,
+// with no OUT endpoint on variants that only send input.
+type usbTransport struct {
+	device *gousb.Device
+	config *gousb.Config
+	intf   *gousb.Interface
+	in     *gousb.InEndpoint
+	out    *gousb.OutEndpoint
+}
+
+func (t *usbTransport) ReadReport(ctx context.Context, buf []byte) (int, error) {
+	return t.in.ReadContext(ctx, buf)
+}
+
+func (t *usbTransport) canWrite() bool {
+	return t.out != nil
+}
+
+func (t *usbTransport) WriteReport(report []byte) error {
+	if t.out == nil {
+		return ErrReadOnly
+	}
+	n, err := t.out.Write(report)
+	return checkWrite(n, len(report), err)
+}
+
+// checkWrite turns a short write into io.ErrShortWrite. Each write is one
+// GIP or HID packet, so sending the rest separately would not be the same
+// packet; a truncated rumble or init command is reported instead.
+func checkWrite(n, want int, err error) error {
+	if err == nil && n < want {
+		return fmt.Errorf("%w: sent %d of %d bytes", io.ErrShortWrite, n, want)
+	}
+	return err
+}
+
+func (t *usbTransport) Close() error {
+	t.intf.Close()
+	t.config.Close()
+	return t.device.Close()
+}
+
+func (t *usbTransport) Info() ControllerInfo {
+	return newControllerInfo(t.device.Desc)
+}
+
+func (t *usbTransport) Strings() (DeviceStrings, error) {
+	var s DeviceStrings
+	var err error
+	if s.Manufacturer, err = t.device.Manufacturer(); err != nil {
+		return s, fmt.Errorf("reading manufacturer failed: %v", err)
+	}
+	if s.Product, err = t.device.Product(); err != nil {
+		return s, fmt.Errorf("reading product failed: %v", err)
+	}
+	if s.Serial, err = t.device.SerialNumber(); err != nil {
+		return s, fmt.Errorf("reading serial number failed: %v", err)
+	}
+	return s, nil
+}
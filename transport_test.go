@@ -0,0 +1,110 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"io"
+	"sync"
+	"testing"
+)
+
+// fakeTransport serves queued reports and read errors in order, then blocks
+// like an idle controller until the read is cancelled. Writes are recorded.
+type fakeTransport struct {
+	mu      sync.Mutex
+	reads   []fakeRead
+	writes  [][]byte
+	short   bool
+	noOut   bool
+	closed  int
+	info    ControllerInfo
+	strings DeviceStrings
+}
+
+type fakeRead struct {
+	report []byte
+	err    error
+}
+
+func (t *fakeTransport) queue(reports ...[]byte) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, r := range reports {
+		t.reads = append(t.reads, fakeRead{report: r})
+	}
+}
+
+func (t *fakeTransport) queueErr(errs ...error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, err := range errs {
+		t.reads = append(t.reads, fakeRead{err: err})
+	}
+}
+
+func (t *fakeTransport) ReadReport(ctx context.Context, buf []byte) (int, error) {
+	t.mu.Lock()
+	if len(t.reads) > 0 {
+		r := t.reads[0]
+		t.reads = t.reads[1:]
+		t.mu.Unlock()
+		return copy(buf, r.report), r.err
+	}
+	t.mu.Unlock()
+	<-ctx.Done()
+	return 0, ctx.Err()
+}
+
+func (t *fakeTransport) WriteReport(report []byte) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.writes = append(t.writes, append([]byte(nil), report...))
+	if t.short {
+		return checkWrite(len(report)-1, len(report), nil)
+	}
+	return nil
+}
+
+func (t *fakeTransport) canWrite() bool { return !t.noOut }
+
+func (t *fakeTransport) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed++
+	return nil
+}
+
+func (t *fakeTransport) Info() ControllerInfo            { return t.info }
+func (t *fakeTransport) Strings() (DeviceStrings, error) { return t.strings, nil }
+
+func (t *fakeTransport) written() [][]byte {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	return append([][]byte(nil), t.writes...)
+}
+
+// pressReport is a 0x20 input report with the named buttons held.
+func pressReport(buttons ...string) []byte {
+	buf := inputReport(17)
+	for _, name := range buttons {
+		bit := StandardLayout[name]
+		buf[bit.Byte] |= bit.Mask
+	}
+	return buf
+}
+
+func TestShortWrite(t *testing.T) {
+	ft := &fakeTransport{short: true}
+	c, err := NewFromTransport(ft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	if err := c.Initialize(); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("Initialize: got %v, want io.ErrShortWrite", err)
+	}
+	if err := c.SetRumble(Rumble{Strong: 1}); !errors.Is(err, io.ErrShortWrite) {
+		t.Errorf("SetRumble: got %v, want io.ErrShortWrite", err)
+	}
+}
@@ -0,0 +1,121 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+)
+
+type turboButton struct {
+	down bool
+	next time.Time
+}
+
+type turbo struct {
+	half    time.Duration
+	buttons map[string]*turboButton
+}
+
+func newTurbo(rate int, buttons []string) (*turbo, error) {
+	if rate <= 0 {
+		return nil, fmt.Errorf("turbo rate must be positive, got %d", rate)
+	}
+
+	t := &turbo{
+		half:    time.Second / time.Duration(2*rate),
+		buttons: make(map[string]*turboButton),
+	}
+
+	var s ControllerState
+	for _, name := range buttons {
+		name = strings.ToUpper(strings.TrimSpace(name))
+		if s.button(name) == nil {
+			return nil, fmt.Errorf("unknown turbo button %q", name)
+		}
+		t.buttons[name] = &turboButton{}
+	}
+	return t, nil
+}
+
+// apply rewrites diff so held turbo buttons alternate between synthetic
+// press and release edges; the decoded state itself is left untouched.
+func (t *turbo) apply(state *ControllerState, diff *StateDiff, now time.Time) {
+	released := diff.Released[:0]
+	for _, name := range diff.Released {
+		if tb, ok := t.buttons[name]; ok {
+			if !tb.down {
+				continue
+			}
+			tb.down = false
+		}
+		released = append(released, name)
+	}
+	diff.Released = released
+
+	for _, name := range diff.Pressed {
+		if tb, ok := t.buttons[name]; ok {
+			tb.down = true
+			tb.next = now.Add(t.half)
+		}
+	}
+
+	for name, tb := range t.buttons {
+		if !*state.button(name) || tb.next.IsZero() || now.Before(tb.next) {
+			continue
+		}
+
+		tb.down = !tb.down
+		tb.next = tb.next.Add(t.half)
+		if tb.next.Before(now) {
+			tb.next = now.Add(t.half)
+		}
+
+		if tb.down {
+			diff.Pressed = append(diff.Pressed, name)
+		} else {
+			diff.Released = append(diff.Released, name)
+		}
+	}
+}
+
+// next is the earliest toggle due for a turbo button held in state.
+func (t *turbo) next(state *ControllerState) (time.Time, bool) {
+	var next time.Time
+	for name, tb := range t.buttons {
+		if *state.button(name) && !tb.next.IsZero() && (next.IsZero() || tb.next.Before(next)) {
+			next = tb.next
+		}
+	}
+	return next, !next.IsZero()
+}
+
+// turboDeadline bounds the next read by the next turbo toggle. The
+// controller sends nothing while its input is unchanged, so a held button
+// would otherwise only auto-fire when some other input moved.
+func (c *Controller) turboDeadline(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.turbo == nil || c.last == nil {
+		return ctx, func() {}
+	}
+	next, ok := c.turbo.next(c.last)
+	if !ok {
+		return ctx, func() {}
+	}
+	return context.WithDeadline(ctx, next)
+}
+
+// turboTick repeats the last state with the edges turbo makes at now, for
+// when a toggle falls due before the next report arrives.
+func (c *Controller) turboTick(now time.Time) (*ControllerState, StateDiff) {
+	state := *c.last
+	state.Time = now
+	var diff StateDiff
+	c.turbo.apply(&state, &diff, now)
+	return &state, diff
+}
+
+func (t *turbo) reset() {
+	for _, tb := range t.buttons {
+		*tb = turboButton{}
+	}
+}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestTurboFiresWithoutNewReports(t *testing.T) {
+	ft := &fakeTransport{}
+	ft.queue(pressReport(), pressReport("A"))
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithBlocking(true), WithTurbo(50, "A"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	// The second report presses A; everything after it comes from the
+	// turbo ticker alone.
+	ctx, cancel := context.WithTimeout(context.Background(), 200*time.Millisecond)
+	defer cancel()
+	var presses, releases int
+	c.Run(ctx, func(state *ControllerState, diff StateDiff) error {
+		for _, name := range diff.Pressed {
+			if name == "A" {
+				presses++
+			}
+		}
+		for _, name := range diff.Released {
+			if name == "A" {
+				releases++
+			}
+		}
+		return nil
+	})
+
+	// 50Hz toggles every 10ms, so 200ms gives about 10 of each.
+	if presses < 5 || releases < 5 {
+		t.Fatalf("got %d presses and %d releases of a held turbo button, want at least 5 each", presses, releases)
+	}
+}
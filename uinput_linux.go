@@ -0,0 +1,241 @@
+//go:build linux
+
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	uiDevCreate  = 0x5501
+	uiDevDestroy = 0x5502
+	uiDevSetup   = 0x405c5503
+	uiAbsSetup   = 0x401c5504
+	uiSetEvBit   = 0x40045564
+	uiSetKeyBit  = 0x40045565
+	uiSetAbsBit  = 0x40045567
+
+	evSyn = 0x00
+	evKey = 0x01
+	evAbs = 0x03
+
+	busUSB = 0x03
+)
+
+// evdev codes in ButtonNames and AxisNames order, matching what the kernel
+// xpad driver reports so games see a regular Xbox pad.
+var (
+	uinputKeys = [16]uint16{
+		0x130, 0x131, 0x133, 0x134, // BTN_A, BTN_B, BTN_X, BTN_Y
+		0x137, 0x136, // BTN_TR, BTN_TL
+		0x220, 0x223, 0x221, 0x222, // BTN_DPAD_UP, RIGHT, DOWN, LEFT
+		0x13d, 0x13e, // BTN_THUMBL, BTN_THUMBR
+		0x13b, 0x13a, 0x13c, // BTN_START, BTN_SELECT, BTN_MODE
+		167, // KEY_RECORD
+	}
+	uinputAxes = [6]uint16{
+		0x02, 0x05, // ABS_Z, ABS_RZ
+		0x00, 0x01, 0x03, 0x04, // ABS_X, ABS_Y, ABS_RX, ABS_RY
+	}
+)
+
+const (
+	guideIndex   = 14
+	triggerMax   = 1023
+	stickMax     = 32767
+	inputEventSz = int(unsafe.Sizeof(syscall.Timeval{})) + 8
+)
+
+// VirtualGamepad is a uinput device that mirrors the controller. Update
+// feeds it decoded state, after deadzone, remapping and the rest of the
+// pipeline; WriteReport is the fast path that maps raw GIP input report
+// bits straight to evdev codes and skips all of that.
+type VirtualGamepad struct {
+	f      *os.File
+	layout [16]ButtonBit
+	keys   [16]int32
+	axes   [6]int32
+	events []byte
+}
+
+func NewVirtualGamepad(layout ButtonLayout) (*VirtualGamepad, error) {
+	f, err := os.OpenFile("/dev/uinput", os.O_WRONLY|syscall.O_NONBLOCK, 0)
+	if err != nil {
+		return nil, fmt.Errorf("opening /dev/uinput failed: %w", usbError(err))
+	}
+	g := &VirtualGamepad{f: f}
+	for i, name := range ButtonNames {
+		g.layout[i] = layout[name]
+	}
+
+	if err := g.create(); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return g, nil
+}
+
+func (g *VirtualGamepad) ioctl(req, arg uintptr) error {
+	if _, _, errno := syscall.Syscall(syscall.SYS_IOCTL, g.f.Fd(), req, arg); errno != 0 {
+		return errno
+	}
+	return nil
+}
+
+func (g *VirtualGamepad) create() error {
+	if err := g.ioctl(uiSetEvBit, evKey); err != nil {
+		return fmt.Errorf("enabling key events failed: %v", err)
+	}
+	for _, code := range uinputKeys {
+		if err := g.ioctl(uiSetKeyBit, uintptr(code)); err != nil {
+			return fmt.Errorf("enabling key 0x%x failed: %v", code, err)
+		}
+	}
+
+	if err := g.ioctl(uiSetEvBit, evAbs); err != nil {
+		return fmt.Errorf("enabling axis events failed: %v", err)
+	}
+	for i, code := range uinputAxes {
+		if err := g.ioctl(uiSetAbsBit, uintptr(code)); err != nil {
+			return fmt.Errorf("enabling axis 0x%x failed: %v", code, err)
+		}
+
+		// struct uinput_abs_setup: code, padding, then input_absinfo
+		// {value, minimum, maximum, fuzz, flat, resolution}.
+		var abs [28]byte
+		binary.NativeEndian.PutUint16(abs[0:], code)
+		min, max, fuzz, flat := int32(-stickMax-1), int32(stickMax), int32(16), int32(128)
+		if isTrigger(AxisNames[i]) {
+			min, max, fuzz, flat = 0, triggerMax, 0, 0
+		}
+		for j, v := range []int32{0, min, max, fuzz, flat, 0} {
+			binary.NativeEndian.PutUint32(abs[4+4*j:], uint32(v))
+		}
+		if err := g.ioctl(uiAbsSetup, uintptr(unsafe.Pointer(&abs[0]))); err != nil {
+			return fmt.Errorf("configuring axis 0x%x failed: %v", code, err)
+		}
+	}
+
+	// struct uinput_setup: input_id {bustype, vendor, product, version},
+	// name[80], ff_effects_max.
+	var setup [92]byte
+	binary.NativeEndian.PutUint16(setup[0:], busUSB)
+	binary.NativeEndian.PutUint16(setup[2:], VendorMicrosoft)
+	binary.NativeEndian.PutUint16(setup[4:], ProductXboxOneS)
+	binary.NativeEndian.PutUint16(setup[6:], 1)
+	copy(setup[8:87], "Xbox One Controller (xboxinput)")
+	if err := g.ioctl(uiDevSetup, uintptr(unsafe.Pointer(&setup[0]))); err != nil {
+		return fmt.Errorf("configuring virtual gamepad failed: %v", err)
+	}
+	if err := g.ioctl(uiDevCreate, 0); err != nil {
+		return fmt.Errorf("creating virtual gamepad failed: %v", err)
+	}
+	return nil
+}
+
+func (g *VirtualGamepad) Update(s *ControllerState) error {
+	keys := g.keys
+	for i, name := range ButtonNames {
+		keys[i] = 0
+		if *s.button(name) {
+			keys[i] = 1
+		}
+	}
+
+	var axes [6]int32
+	for i, name := range AxisNames {
+		v := float64(*s.axis(name))
+		switch {
+		case isTrigger(name):
+			axes[i] = int32(math.Round(v * triggerMax))
+		case name == "LEFTY" || name == "RIGHTY":
+			axes[i] = int32(math.Round(-v * stickMax))
+		default:
+			axes[i] = int32(math.Round(v * stickMax))
+		}
+	}
+	return g.send(keys, axes)
+}
+
+// WriteReport translates a raw USB input report without decoding it into
+// ControllerState. Reports other than 0x20 and the guide report are ignored.
+func (g *VirtualGamepad) WriteReport(report []byte) error {
+	keys, axes := g.keys, g.axes
+
+	switch {
+	case len(report) >= minReportLen[reportInput] && report[0] == reportInput:
+		for i, bit := range g.layout {
+			if bit.Mask == 0 || bit.Byte >= len(report) {
+				continue
+			}
+			keys[i] = 0
+			if report[bit.Byte]&bit.Mask != 0 {
+				keys[i] = 1
+			}
+		}
+		axes[0] = int32(binary.LittleEndian.Uint16(report[5:7]))
+		axes[1] = int32(binary.LittleEndian.Uint16(report[7:9]))
+		for i := 0; i < 4; i++ {
+			v := int32(int16(binary.LittleEndian.Uint16(report[9+2*i:])))
+			if i%2 == 1 {
+				// evdev Y grows downwards; GIP Y grows upwards.
+				v = -v
+				if v > stickMax {
+					v = stickMax
+				}
+			}
+			axes[2+i] = v
+		}
+	case len(report) >= minReportLen[reportGuide] && report[0] == reportGuide:
+		keys[guideIndex] = int32(report[4] & 0x01)
+	default:
+		return nil
+	}
+	return g.send(keys, axes)
+}
+
+// send writes only the codes that changed, followed by one SYN_REPORT.
+func (g *VirtualGamepad) send(keys [16]int32, axes [6]int32) error {
+	g.events = g.events[:0]
+	for i, v := range keys {
+		if v != g.keys[i] {
+			g.event(evKey, uinputKeys[i], v)
+		}
+	}
+	for i, v := range axes {
+		if v != g.axes[i] {
+			g.event(evAbs, uinputAxes[i], v)
+		}
+	}
+	if len(g.events) == 0 {
+		return nil
+	}
+	g.event(evSyn, 0, 0)
+
+	g.keys, g.axes = keys, axes
+	if _, err := g.f.Write(g.events); err != nil {
+		return fmt.Errorf("writing to virtual gamepad failed: %v", err)
+	}
+	return nil
+}
+
+// event appends a struct input_event with a zero timestamp, which the
+// kernel fills in.
+func (g *VirtualGamepad) event(typ, code uint16, value int32) {
+	var ev [inputEventSz]byte
+	off := inputEventSz - 8
+	binary.NativeEndian.PutUint16(ev[off:], typ)
+	binary.NativeEndian.PutUint16(ev[off+2:], code)
+	binary.NativeEndian.PutUint32(ev[off+4:], uint32(value))
+	g.events = append(g.events, ev[:]...)
+}
+
+func (g *VirtualGamepad) Close() error {
+	g.ioctl(uiDevDestroy, 0)
+	return g.f.Close()
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"os"
+	"testing"
+)
+
+// loopTransport repeats its reports forever, for benchmarks.
+type loopTransport struct {
+	fakeTransport
+	reports [][]byte
+	n       int
+}
+
+func (t *loopTransport) ReadReport(ctx context.Context, buf []byte) (int, error) {
+	r := t.reports[t.n%len(t.reports)]
+	t.n++
+	return copy(buf, r), nil
+}
+
+// benchPad is a VirtualGamepad writing to /dev/null, so the benchmarks need
+// no uinput access.
+func benchPad(b *testing.B) (*VirtualGamepad, *loopTransport) {
+	f, err := os.OpenFile(os.DevNull, os.O_WRONLY, 0)
+	if err != nil {
+		b.Fatal(err)
+	}
+	b.Cleanup(func() { f.Close() })
+	g := &VirtualGamepad{f: f}
+	for i, name := range ButtonNames {
+		g.layout[i] = StandardLayout[name]
+	}
+
+	// Alternate between two reports so every one produces events.
+	a, x := pressReport("A"), pressReport("X")
+	binary.LittleEndian.PutUint16(a[9:11], 20000)
+	binary.LittleEndian.PutUint16(x[5:7], 800)
+	return g, &loopTransport{reports: [][]byte{a, x}}
+}
+
+func BenchmarkReadRaw(b *testing.B) {
+	g, t := benchPad(b)
+	buf := make([]byte, 64)
+	for i := 0; i < b.N; i++ {
+		n, _ := t.ReadReport(context.Background(), buf)
+		if err := g.WriteReport(buf[:n]); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkDecode(b *testing.B) {
+	g, t := benchPad(b)
+	c, err := NewFromTransport(t, WithReadOnly(true))
+	if err != nil {
+		b.Fatal(err)
+	}
+	defer c.Close()
+	for i := 0; i < b.N; i++ {
+		state, err := c.ReadState()
+		if err != nil {
+			b.Fatal(err)
+		}
+		if err := g.Update(state); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
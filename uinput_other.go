@@ -0,0 +1,23 @@
+//go:build !linux
+
+package main
+
+import "fmt"
+
+type VirtualGamepad struct{}
+
+func NewVirtualGamepad(layout ButtonLayout) (*VirtualGamepad, error) {
+	return nil, fmt.Errorf("virtual gamepads are only supported on Linux")
+}
+
+func (g *VirtualGamepad) Update(s *ControllerState) error {
+	return nil
+}
+
+func (g *VirtualGamepad) WriteReport(report []byte) error {
+	return nil
+}
+
+func (g *VirtualGamepad) Close() error {
+	return nil
+}
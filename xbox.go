@@ -1,274 +1,674 @@
 package main
 
 import (
-	"encoding/binary"
-	"flag"
+	"bytes"
+	"context"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"log"
-	"math"
-	"reflect"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/google/gousb"
 )
 
-var (
-	pollingFrequency = flag.Int("freq", 500, "Polling frequency in Hz")
-	readonly         = flag.Bool("readonly", false, "Only read from the controller")
-	debug            = flag.Int("debug", 0, "USB debugging control")
-)
-
 const (
-	VendorMicrosoft  = 0x045e
-	ProductXboxOne   = 0x02d1
-	ProductXboxOneS  = 0x02dd
-	ProductXboxOneX  = 0x02ea
-	ProductXboxElite = 0x02e3
+	VendorMicrosoft     = 0x045e
+	ProductXboxOne      = 0x02d1
+	ProductXboxOneS     = 0x02dd
+	ProductXboxOneX     = 0x02ea
+	ProductXboxElite    = 0x02e3
+	ProductXboxAdaptive = 0x0b0a
 )
 
+var supportedProducts = knownProducts(TransportUSB)
+
 type Controller struct {
-	device *gousb.Device
-	config *gousb.Config
-	intf   *gousb.Interface
-	in     *gousb.InEndpoint
-	out    *gousb.OutEndpoint
+	transport Transport
+	info      ControllerInfo
+
+	cfg    Config
+	ctx    context.Context
+	last   *ControllerState
+	player int
+	serial string
+	turbo  *turbo
+	hold   *longPress
+	dpad   *stickDpad
+	lt, rt hysteresis
+	macros sync.WaitGroup
+
+	shiftLatch map[string]bool
+
+	// closeUSB is nil when the libusb context belongs to the caller.
+	usb      *gousb.Context
+	closeUSB func()
+
+	// reopen replaces reopenDevice when set, letting reconnects be driven
+	// without hardware.
+	reopen func() error
+
+	mu           sync.Mutex
+	lastRead     time.Time
+	disconnected bool
+	curSample    stateSample
+	prevSample   stateSample
+	stats        controllerStats
+
+	merged ControllerState
+	raw    ControllerState
+
+	outMu  sync.Mutex
+	seq    byte
+	rumble Rumble
+
+	patternMu sync.Mutex
+	pattern   *rumblePattern
+
+	pause pauser
+
+	impossible string
+
+	onGuide func(pressed bool)
+
+	// guideReport is set once the controller is seen sending the guide
+	// button in its own HID report 0x02.
+	guideReport bool
+
+	closed bool
 }
 
 type ControllerState struct {
 	A, B, X, Y, RB, LB, UP, RIGHT, DOWN, LEFT, LS, RS, MENU, VIEW, GUIDE, SHARE bool
 	LT, RT, LEFTX, LEFTY, RIGHTX, RIGHTY                                        float32
-	LastState                                                                   *ControllerState
+	ReportID                                                                    byte
+	LastState                                                                   *ControllerState `json:"-"`
+
+	// LTPressed and RTPressed are the triggers as digital buttons, with
+	// separate press and release thresholds.
+	LTPressed, RTPressed bool
+
+	// Time is when the host received the report, taken right after the read
+	// returned; the controller sends no timestamp of its own.
+	Time time.Time
+
+	// Extra holds any input report bytes past the standard layout, such as
+	// the additional inputs the Adaptive Controller sends. It is nil for
+	// controllers that send only the standard report.
+	Extra []byte `json:",omitempty"`
 }
 
 func NewController() (*Controller, error) {
-	ctx := gousb.NewContext()
+	return New()
+}
 
-	for _, pid := range []gousb.ID{ProductXboxOne, ProductXboxOneS, ProductXboxOneX, ProductXboxElite} {
-		device, err := ctx.OpenDeviceWithVIDPID(VendorMicrosoft, pid)
-		if err != nil {
-			continue
-		}
+func New(opts ...Option) (*Controller, error) {
+	c, err := newController(opts)
+	if err != nil {
+		return nil, err
+	}
 
-		if device == nil {
-			continue
+	if c.cfg.Transport == TransportBluetooth {
+		if err := c.openBluetooth(); err != nil {
+			return nil, err
 		}
+		return c, nil
+	}
 
-		log.Printf("Found Xbox controller with PID: %#x", pid)
+	usb := gousb.NewContext()
+	if err := c.open(usb); err != nil {
+		usb.Close()
+		return nil, err
+	}
+	c.closeUSB = func() { usb.Close() }
+	return c, nil
+}
 
-		config, err := device.Config(1)
-		if err != nil {
+// NewFromContext opens a controller through a caller-managed libusb context.
+// Close releases the device but leaves the context open for its owner.
+func NewFromContext(usb *gousb.Context, opts ...Option) (*Controller, error) {
+	c, err := newController(opts)
+	if err != nil {
+		return nil, err
+	}
+	if c.cfg.Transport != TransportUSB {
+		return nil, fmt.Errorf("NewFromContext only supports the USB transport")
+	}
+	if err := c.open(usb); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+func (c *Controller) open(usb *gousb.Context) error {
+	c.usb = usb
+	devices, err := openDevices(usb, c.cfg.Model)
+	var claimErr error
+	for _, device := range devices {
+		if c.transport != nil {
 			device.Close()
 			continue
 		}
-
-		intf, err := config.Interface(0, 0)
-		if err != nil {
-			config.Close()
+		if claimErr = c.claim(device); claimErr != nil {
 			device.Close()
-			continue
 		}
+	}
 
-		in, err := intf.InEndpoint(1)
-		if err != nil {
-			intf.Close()
-			config.Close()
+	if c.transport != nil {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if claimErr != nil {
+		return fmt.Errorf("claiming controller failed: %w", usbError(claimErr))
+	}
+	if c.cfg.Model != ModelAny {
+		return fmt.Errorf("%w for model %s", ErrNoDevice, c.cfg.Model)
+	}
+	return ErrNoDevice
+}
+
+func OpenAll(opts ...Option) ([]*Controller, error) {
+	template, err := newController(opts)
+	if err != nil {
+		return nil, err
+	}
+	if template.cfg.Transport != TransportUSB {
+		return nil, fmt.Errorf("OpenAll only supports the USB transport")
+	}
+
+	usb := gousb.NewContext()
+	devices, err := openDevices(usb, template.cfg.Model)
+
+	var controllers []*Controller
+	for _, device := range devices {
+		c := &Controller{cfg: template.cfg, ctx: template.ctx, usb: usb}
+		if err := c.setup(); err != nil {
 			device.Close()
 			continue
 		}
-
-		out, err := intf.OutEndpoint(1)
-		if err != nil {
-			intf.Close()
-			config.Close()
+		if claimErr := c.claim(device); claimErr != nil {
 			device.Close()
 			continue
 		}
+		c.player = len(controllers) + 1
+		controllers = append(controllers, c)
+	}
 
-		return &Controller{
-			device: device,
-			config: config,
-			intf:   intf,
-			in:     in,
-			out:    out,
-		}, nil
+	if len(controllers) > 0 {
+		// The controllers share one context, closed along with the last of them.
+		var mu sync.Mutex
+		refs := len(controllers)
+		for _, c := range controllers {
+			c.closeUSB = func() {
+				mu.Lock()
+				defer mu.Unlock()
+				if refs--; refs == 0 {
+					usb.Close()
+				}
+			}
+		}
+		return controllers, nil
+	}
+	usb.Close()
+	if err != nil {
+		return nil, err
 	}
+	return nil, ErrNoDevice
+}
+
+const waitPollInterval = 500 * time.Millisecond
 
-	return nil, fmt.Errorf("no compatible Xbox controller found")
+// WaitForModel polls until a controller of model m is plugged in and opens
+// it with opts. Errors other than no device being found end the wait,
+// including asking for the Bluetooth transport, which cannot tell models
+// apart.
+func WaitForModel(ctx context.Context, m Model, opts ...Option) (*Controller, error) {
+	opts = append(opts[:len(opts):len(opts)], WithModel(m))
+	for {
+		c, err := New(opts...)
+		if err == nil {
+			return c, nil
+		}
+		if !errors.Is(err, ErrNoDevice) {
+			return nil, err
+		}
+
+		select {
+		case <-time.After(waitPollInterval):
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+	}
 }
 
-func (c *Controller) Close() {
-	if c.intf != nil {
-		c.intf.Close()
+func newController(opts []Option) (*Controller, error) {
+	c := &Controller{
+		cfg: DefaultConfig(),
+		ctx: context.Background(),
 	}
-	if c.config != nil {
-		c.config.Close()
+	for _, opt := range opts {
+		opt(c)
 	}
-	if c.device != nil {
-		c.device.Close()
+
+	if err := c.cfg.Layout.Validate(); err != nil {
+		return nil, err
 	}
+	if c.cfg.Deadzone < 0 || c.cfg.Deadzone >= 1 {
+		return nil, fmt.Errorf("deadzone must be in [0, 1), got %v", c.cfg.Deadzone)
+	}
+	if c.cfg.TriggerDeadzone < 0 || c.cfg.TriggerDeadzone >= 1 {
+		return nil, fmt.Errorf("trigger deadzone must be in [0, 1), got %v", c.cfg.TriggerDeadzone)
+	}
+	if c.cfg.Model != ModelAny && c.cfg.Transport == TransportBluetooth {
+		return nil, fmt.Errorf("model %s can only be selected over USB", c.cfg.Model)
+	}
+	if c.cfg.EventBuffer < 0 {
+		return nil, fmt.Errorf("event buffer must not be negative, got %d", c.cfg.EventBuffer)
+	}
+
+	return c, c.setup()
 }
 
-func (c *Controller) Initialize() error {
-	init := []byte{0x05, 0x20}
-	_, err := c.out.Write(init)
-	if err != nil {
-		return fmt.Errorf("initialization failed: %v", err)
+func (c *Controller) setup() error {
+	if len(c.cfg.TurboButtons) > 0 {
+		t, err := newTurbo(c.cfg.TurboRate, c.cfg.TurboButtons)
+		if err != nil {
+			return err
+		}
+		c.turbo = t
+	}
+	if len(c.cfg.LongPressButtons) > 0 {
+		l, err := newLongPress(c.cfg.LongPress, c.cfg.LongPressButtons)
+		if err != nil {
+			return err
+		}
+		c.hold = l
+	}
+	if c.cfg.Remap != nil && c.cfg.Remap.Shift != "" {
+		c.shiftLatch = make(map[string]bool)
+	}
+	if c.cfg.StickDpad != 0 {
+		d, err := newStickDpad(c.cfg.StickDpad, c.cfg.StickDpadThreshold, c.cfg.StickDpadRelease)
+		if err != nil {
+			return err
+		}
+		c.dpad = d
 	}
 
-	time.Sleep(100 * time.Millisecond)
+	lt, err := newHysteresis("trigger", c.cfg.TriggerThreshold, c.cfg.TriggerRelease)
+	if err != nil {
+		return err
+	}
+	if c.cfg.TriggerHoldTime < 0 {
+		return fmt.Errorf("trigger hold time must not be negative, got %v", c.cfg.TriggerHoldTime)
+	}
+	lt.hold = c.cfg.TriggerHoldTime
+	c.lt, c.rt = lt, lt
 	return nil
 }
 
-func (c *Controller) ReadState() (*ControllerState, error) {
-	buf := make([]byte, 64)
-	n, err := c.in.Read(buf)
-	if err != nil {
-		return nil, err
-	}
+func openDevices(usb *gousb.Context, model Model) ([]*gousb.Device, error) {
+	products := model.products()
 
-	if n < 16 {
-		return nil, fmt.Errorf("short read: %d bytes", n)
-	}
-
-	state := &ControllerState{}
-
-	switch buf[0] {
-	case 0x20:
-		btn1 := buf[3]
-		btn2 := buf[4]
-
-		state.A = btn1&0x10 != 0
-		state.B = btn1&0x40 != 0
-		state.X = btn1&0x20 != 0
-		state.Y = btn1&0x80 != 0
-		state.MENU = btn1&0x04 != 0
-		state.VIEW = btn1&0x08 != 0
-		state.SHARE = btn1&0x01 != 0
-		state.UP = btn2&0x01 != 0
-		state.DOWN = btn2&0x02 != 0
-		state.LEFT = btn2&0x04 != 0
-		state.RIGHT = btn2&0x08 != 0
-		state.LB = btn2&0x10 != 0
-		state.RB = btn2&0x20 != 0
-		state.LS = btn2&0x40 != 0
-		state.RS = btn2&0x80 != 0
-		lt := binary.LittleEndian.Uint16(buf[5:7])
-		rt := binary.LittleEndian.Uint16(buf[7:9])
-		state.LT = float32(lt) / 1023.0
-		state.RT = float32(rt) / 1023.0
-		lx := int16(binary.LittleEndian.Uint16(buf[9:11]))
-		ly := int16(binary.LittleEndian.Uint16(buf[11:13]))
-		rx := int16(binary.LittleEndian.Uint16(buf[13:15]))
-		ry := int16(binary.LittleEndian.Uint16(buf[15:17]))
-		state.LEFTX = float32(lx) / 32768.0
-		state.LEFTY = float32(ly) / 32768.0
-		state.RIGHTX = float32(rx) / 32768.0
-		state.RIGHTY = float32(ry) / 32768.0
-
-		const deadzone = 0.1
-		if math.Abs(float64(state.LEFTX)) < deadzone {
-			state.LEFTX = 0
+	devices, err := usb.OpenDevices(func(desc *gousb.DeviceDesc) bool {
+		if desc.Vendor != VendorMicrosoft {
+			return false
 		}
-		if math.Abs(float64(state.LEFTY)) < deadzone {
-			state.LEFTY = 0
+		for _, pid := range products {
+			if desc.Product == pid {
+				return true
+			}
 		}
-		if math.Abs(float64(state.RIGHTX)) < deadzone {
-			state.RIGHTX = 0
+		return false
+	})
+	if err != nil && len(devices) == 0 {
+		return nil, fmt.Errorf("opening USB devices failed: %w", usbError(err))
+	}
+	return devices, nil
+}
+
+func (c *Controller) claim(device *gousb.Device) error {
+	log.Printf("Found Xbox controller with PID: %#x", uint16(device.Desc.Product))
+
+	config, err := device.Config(1)
+	if err != nil {
+		return err
+	}
+
+	intf, err := config.Interface(c.cfg.Interface, 0)
+	if err != nil {
+		config.Close()
+		if errors.Is(err, gousb.ErrorBusy) {
+			return fmt.Errorf("interface %d is already claimed; close other programs using the controller (Steam, another xbox-controller) or unbind the xpad kernel driver: %w", c.cfg.Interface, err)
 		}
-		if math.Abs(float64(state.RIGHTY)) < deadzone {
-			state.RIGHTY = 0
+		return err
+	}
+
+	in, err := intf.InEndpoint(c.cfg.InEndpoint)
+	if err != nil {
+		intf.Close()
+		config.Close()
+		return err
+	}
+
+	// Some variants expose no OUT endpoint on this interface; they still
+	// deliver input, so fall back to read-only instead of failing.
+	var out *gousb.OutEndpoint
+	if c.cfg.OutEndpoint > 0 {
+		out, err = intf.OutEndpoint(c.cfg.OutEndpoint)
+		if err != nil {
+			log.Printf("No output endpoint, opening read-only: %v", err)
+			out = nil
 		}
+	}
+	c.attach(&usbTransport{device: device, config: config, intf: intf, in: in, out: out})
+
+	if serial, err := device.SerialNumber(); err == nil {
+		c.serial = serial
+	}
+	return nil
+}
 
-	case 0x07:
-		if len(buf) >= 4 {
-			state.GUIDE = buf[2]&0x01 != 0
+// Close is safe to call more than once and on the nil controller returned
+// by a failed New.
+func (c *Controller) Close() {
+	if c == nil || c.closed {
+		return
+	}
+
+	c.CancelRumblePattern()
+	if !c.readOnly() && c.transport != nil {
+		if err := c.setRumble(Rumble{}); err != nil {
+			log.Printf("Failed to stop rumble on close: %v", err)
 		}
 	}
+	c.closed = true
 
-	return state, nil
+	c.release()
+	if c.closeUSB != nil {
+		c.closeUSB()
+	}
 }
 
-func setPollingFrequency(hz int) time.Duration {
-	if hz <= 0 {
-		return 16 * time.Millisecond
+// Reset forgets everything accumulated from earlier reports so the next read
+// starts from a neutral state; the device itself stays open. Call it from the
+// goroutine that reads, e.g. after a resume or reconnect. A held guide button
+// is reported to OnGuide as released.
+func (c *Controller) Reset() {
+	guide := c.raw.GUIDE
+	c.merged = ControllerState{}
+	c.raw = ControllerState{}
+	c.last = nil
+	c.cfg.Timer.restart()
+
+	c.mu.Lock()
+	c.curSample = stateSample{}
+	c.prevSample = stateSample{}
+	c.mu.Unlock()
+
+	c.outMu.Lock()
+	c.seq = 0
+	c.outMu.Unlock()
+
+	if c.turbo != nil {
+		c.turbo.reset()
 	}
-	return time.Duration(1e9/hz) * time.Nanosecond
+	if c.hold != nil {
+		c.hold.reset()
+	}
+	if c.dpad != nil {
+		c.dpad.push.reset()
+	}
+	c.lt.reset()
+	c.rt.reset()
+	for name := range c.shiftLatch {
+		delete(c.shiftLatch, name)
+	}
+	c.notifyGuide(guide)
 }
 
-func logStateChanges(current, last *ControllerState) {
-	if last == nil {
-		return
+func (c *Controller) PollInterval() time.Duration {
+	return setPollingFrequency(c.cfg.PollRate)
+}
+
+func (c *Controller) write(data []byte) error {
+	if c.closed {
+		return ErrClosed
+	}
+	if c.readOnly() {
+		return ErrReadOnly
 	}
+	if c.transport == nil {
+		return ErrDisconnected
+	}
+	c.cfg.Capture.record(CaptureOut, data)
+	return usbError(c.transport.WriteReport(data))
+}
 
-	val := reflect.ValueOf(*current)
-	lastVal := reflect.ValueOf(*last)
-	t := val.Type()
+// Initialize sends the init packets. It is the one place that decides init
+// is skipped: over Bluetooth, which needs none, and when read-only, so
+// callers can always call it.
+func (c *Controller) Initialize() error {
+	if c.bluetooth() || c.readOnly() {
+		return nil
+	}
 
-	for i := 0; i < val.NumField(); i++ {
-		field := t.Field(i)
+	packets := c.cfg.InitPackets
+	if len(packets) == 0 {
+		packets = [][]byte{{0x05, 0x20}}
+	}
+	for _, init := range packets {
+		if err := c.write(init); err != nil {
+			return fmt.Errorf("initialization failed sending % x: %w", init, err)
+		}
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	return nil
+}
 
-		if field.Type.Kind() != reflect.Bool || field.Name == "LastState" {
+// ParseInitPackets reads comma-separated hex packets, with optional spaces
+// or colons between bytes, e.g. "05 20,0a 20 00 03 00 01 14".
+func ParseInitPackets(spec string) ([][]byte, error) {
+	var packets [][]byte
+	for _, part := range strings.Split(spec, ",") {
+		digits := strings.NewReplacer(" ", "", ":", "", "0x", "").Replace(strings.TrimSpace(part))
+		if digits == "" {
 			continue
 		}
+		packet, err := hex.DecodeString(digits)
+		if err != nil {
+			return nil, fmt.Errorf("invalid init packet %q: %v", part, err)
+		}
+		packets = append(packets, packet)
+	}
+	if len(packets) == 0 {
+		return nil, fmt.Errorf("no init packets in %q", spec)
+	}
+	return packets, nil
+}
 
-		currentValue := val.Field(i).Bool()
-		lastValue := lastVal.Field(i).Bool()
-
-		if currentValue != lastValue {
-			if currentValue {
-				log.Printf("%s pressed", field.Name)
-			} else {
-				log.Printf("%s released", field.Name)
-			}
+// ParseReportIDs reads comma-separated report IDs in decimal or 0x hex,
+// e.g. "0x03,0x0a".
+func ParseReportIDs(spec string) ([]byte, error) {
+	var ids []byte
+	for _, part := range strings.Split(spec, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		id, err := strconv.ParseUint(part, 0, 8)
+		if err != nil {
+			return nil, fmt.Errorf("invalid report ID %q: %v", part, err)
 		}
+		ids = append(ids, byte(id))
 	}
+	return ids, nil
+}
+
+func (c *Controller) ReadState() (*ControllerState, error) {
+	return c.readState(c.ctx)
+}
 
-	const analogThreshold = 0.1
-	if math.Abs(float64(current.LEFTX-last.LEFTX)) > analogThreshold ||
-		math.Abs(float64(current.LEFTY-last.LEFTY)) > analogThreshold {
-		log.Printf("Left stick: %.2f, %.2f", current.LEFTX, current.LEFTY)
+// ReadRawState reads one report and returns it as decoded, with no deadzone,
+// calibration, sensitivity, clamping, remapping or d-pad emulation applied.
+// It does not advance the previous state that ReadStateDiff compares with.
+func (c *Controller) ReadRawState() (*ControllerState, error) {
+	if c.closed {
+		return nil, ErrClosed
 	}
+	state, err := c.readReport(c.ctx)
+	if err != nil {
+		return nil, err
+	}
+	raw := c.raw
+	raw.ReportID = state.ReportID
+	raw.Time = state.Time
+	return &raw, nil
+}
 
-	if math.Abs(float64(current.RIGHTX-last.RIGHTX)) > analogThreshold ||
-		math.Abs(float64(current.RIGHTY-last.RIGHTY)) > analogThreshold {
-		log.Printf("Right stick: %.2f, %.2f", current.RIGHTX, current.RIGHTY)
+func (c *Controller) readState(ctx context.Context) (*ControllerState, error) {
+	if c.closed {
+		return nil, ErrClosed
 	}
 
-	if math.Abs(float64(current.LT-last.LT)) > analogThreshold ||
-		math.Abs(float64(current.RT-last.RT)) > analogThreshold {
-		log.Printf("Triggers: LT=%.2f RT=%.2f", current.LT, current.RT)
+	state, err := c.readReport(ctx)
+	if err != nil {
+		return nil, err
 	}
+
+	c.process(state)
+	if c.isInputReport(state.ReportID) {
+		c.recordSample(state, state.Time)
+	}
+	return state, nil
 }
 
-func main() {
-	flag.Parse()
+func (c *Controller) isInputReport(id byte) bool {
+	if c.hidReports() {
+		return id == reportBluetoothInput
+	}
+	return id == reportInput
+}
 
-	controller, err := NewController()
-	if err != nil {
-		log.Fatalf("Failed to initialize controller: %v", err)
+// The per-axis deadzone is applied while decoding; the radial one and
+// calibration need the raw values, so decoding gets no deadzone and process
+// applies it instead.
+func (c *Controller) axisDeadzone() float32 {
+	if c.cfg.RadialDeadzone || c.cfg.Calibration != nil {
+		return 0
 	}
-	defer controller.Close()
+	return c.cfg.Deadzone
+}
 
-	if err := controller.Initialize(); err != nil {
-		log.Fatalf("Failed to initialize: %v", err)
+func (c *Controller) process(state *ControllerState) {
+	if c.cfg.Calibration != nil {
+		c.cfg.Calibration.apply(state, c.cfg.Deadzone, c.cfg.RadialDeadzone)
+	}
+	if c.cfg.RadialDeadzone {
+		applyRadialDeadzone(state, c.cfg.Deadzone)
+	}
+	applySensitivity(state, c.cfg.LeftSensitivity, c.cfg.RightSensitivity)
+	if c.cfg.ClampSticks {
+		clampSticks(state)
 	}
+	c.cfg.Remap.apply(state, c.shiftLatch)
+	if c.dpad != nil {
+		c.dpad.apply(state)
+	}
+	state.LTPressed = c.lt.update(state.LT, state.Time)
+	state.RTPressed = c.rt.update(state.RT, state.Time)
+}
 
-	sleepDuration := setPollingFrequency(*pollingFrequency)
-	log.Printf("Polling frequency set to %d Hz", *pollingFrequency)
-	log.Println("Xbox One controller connected and initialized")
+func (c *Controller) readReport(ctx context.Context) (*ControllerState, error) {
+	if c.transport == nil {
+		return nil, ErrDisconnected
+	}
 
-	var lastState *ControllerState
+	buf := make([]byte, 64)
+	n, err := c.transport.ReadReport(ctx, buf)
+	at := time.Now()
+	if ctx.Err() == nil {
+		c.recordRead(err, at)
+	}
+	if err != nil {
+		return nil, usbError(err)
+	}
+	c.cfg.Capture.record(CaptureIn, buf[:n])
+	if n > 0 && bytes.IndexByte(c.cfg.IgnoreReports, buf[0]) >= 0 {
+		// Still acknowledged, or the controller keeps resending it.
+		c.acknowledgeIfRequested(buf[:n])
+		state := c.merged
+		state.ReportID = buf[0]
+		state.Time = at
+		return &state, nil
+	}
+	if c.cfg.OnReport != nil {
+		c.cfg.OnReport(buf[:n])
+	}
 
-	for {
-		state, err := controller.ReadState()
-		if err != nil {
-			log.Printf("Read error: %v", err)
-			time.Sleep(100 * time.Millisecond)
-			continue
+	// Decoding without a deadzone keeps the raw values for ReadRawState;
+	// the deadzone is the only processing done at decode time.
+	guide, held := c.raw.GUIDE, c.raw.ButtonMask()
+	if c.hidReports() {
+		if n > 0 && buf[0] == reportBluetoothGuide || c.info.ProductID == ProductXboxOneSBluetoothOld {
+			c.guideReport = true
 		}
+		err = updateBluetoothReport(&c.raw, buf[:n], 0, c.guideReport)
+	} else {
+		err = updateReport(&c.raw, buf[:n], c.cfg.Layout, 0)
+	}
+	if err != nil {
+		c.recordDrop()
+		return nil, err
+	}
+	c.recordPresses(held, c.raw.ButtonMask(), at)
+	c.merged = c.raw
+	applyDeadzone(&c.merged, c.axisDeadzone())
+	applyTriggerDeadzone(&c.merged, c.cfg.TriggerDeadzone)
+	if c.cfg.Diagnostics && c.isInputReport(buf[0]) {
+		c.checkInputs(buf[:n])
+	}
+	c.acknowledgeIfRequested(buf[:n])
+	c.notifyGuide(guide)
+	if c.cfg.Timer != nil && c.isInputReport(buf[0]) {
+		c.cfg.Timer.Record(at)
+	}
+	state := c.merged
+	state.Time = at
+	return &state, nil
+}
+
+const (
+	gipCommandAck  = 0x01
+	gipOptionAck   = 0x10
+	gipOptionInner = 0x20
+)
 
-		logStateChanges(state, lastState)
-		lastState = state
-		time.Sleep(sleepDuration)
+func (c *Controller) acknowledgeIfRequested(report []byte) {
+	if c.hidReports() || len(report) <= 3 || report[1]&gipOptionAck == 0 || c.readOnly() {
+		return
+	}
+	if err := c.acknowledge(report); err != nil {
+		log.Printf("Failed to acknowledge report 0x%02x: %v", report[0], err)
+	}
+}
+
+// The controller keeps resending messages flagged for acknowledgement (the
+// guide button report among them) until the host echoes their sequence
+// number back, which shows up as repeated or delayed GUIDE edges.
+func (c *Controller) acknowledge(report []byte) error {
+	ack := []byte{
+		gipCommandAck, gipOptionInner, report[2], 0x09,
+		0x00, report[0], gipOptionInner, report[3], 0x00, 0x00, 0x00, 0x00, 0x00,
 	}
+
+	c.outMu.Lock()
+	defer c.outMu.Unlock()
+	return c.write(ack)
 }
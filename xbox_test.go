@@ -0,0 +1,106 @@
+package main
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"testing"
+	"time"
+)
+
+func TestZeroDeadzonePassesTinyValues(t *testing.T) {
+	report := pressReport()
+	binary.LittleEndian.PutUint16(report[9:11], 100)
+	ft := &fakeTransport{}
+	ft.queue(report)
+
+	c, err := NewFromTransport(ft, WithReadOnly(true), WithDeadzone(0))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer c.Close()
+
+	state, err := c.ReadState()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := float32(100) / stickRange; state.LEFTX != want {
+		t.Fatalf("LEFTX = %v with deadzone 0, want %v", state.LEFTX, want)
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	ft := &fakeTransport{}
+	c, err := NewFromTransport(ft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c.Close()
+
+	if ft.closed != 1 {
+		t.Fatalf("transport closed %d times, want 1", ft.closed)
+	}
+	if n := len(ft.written()); n != 1 {
+		t.Fatalf("%d writes on close, want one rumble stop", n)
+	}
+}
+
+func TestCloseWithoutTransport(t *testing.T) {
+	var nilController *Controller
+	nilController.Close()
+
+	c, err := newController(nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	c.Close()
+}
+
+func TestIOAfterClose(t *testing.T) {
+	ft := &fakeTransport{}
+	ft.queue(pressReport("A"))
+	c, err := NewFromTransport(ft)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Close()
+	writes := len(ft.written())
+
+	if _, err := c.ReadState(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadState: got %v, want ErrClosed", err)
+	}
+	if _, err := c.ReadRawState(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadRawState: got %v, want ErrClosed", err)
+	}
+	if _, _, err := c.ReadStateDiff(); !errors.Is(err, ErrClosed) {
+		t.Errorf("ReadStateDiff: got %v, want ErrClosed", err)
+	}
+	if err := c.SetRumble(Rumble{Strong: 1}); !errors.Is(err, ErrClosed) {
+		t.Errorf("SetRumble: got %v, want ErrClosed", err)
+	}
+	if err := c.Initialize(); !errors.Is(err, ErrClosed) {
+		t.Errorf("Initialize: got %v, want ErrClosed", err)
+	}
+	err = c.Run(context.Background(), func(*ControllerState, StateDiff) error { return nil })
+	if !errors.Is(err, ErrClosed) {
+		t.Errorf("Run: got %v, want ErrClosed", err)
+	}
+	if n := len(ft.written()); n != writes {
+		t.Errorf("%d writes after Close", n-writes)
+	}
+}
+
+func TestWaitForModelBluetooth(t *testing.T) {
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+	c, err := WaitForModel(ctx, ModelXboxElite, WithTransport(TransportBluetooth))
+	if err == nil {
+		c.Close()
+		t.Fatal("WaitForModel over Bluetooth succeeded")
+	}
+	if errors.Is(err, context.DeadlineExceeded) || errors.Is(err, ErrNoDevice) {
+		t.Fatalf("WaitForModel over Bluetooth kept waiting: %v", err)
+	}
+}